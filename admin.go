@@ -0,0 +1,159 @@
+package main
+
+import (
+	"MT-GO/tools"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminProfileRequest struct {
+	ProfileID string `json:"profileId"`
+}
+
+type AdminBackupRequest struct {
+	ProfileID string `json:"profileId"`
+	Backup    string `json:"backup"`
+}
+
+// adminOnly rejects requests that do not come from the machine the server runs on
+func adminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := net.ParseIP(c.RemoteIP())
+		if ip == nil || !ip.IsLoopback() {
+			sendError(c, BACKEND_FORBIDDEN, "admin routes are only available locally")
+			return
+		}
+		c.Next()
+	}
+}
+
+func adminProfileBackups(c *gin.Context) {
+	body := getBody[AdminBackupRequest](c)
+	if _, ok := getProfile(body.ProfileID); !ok {
+		sendError(c, BACKEND_NOT_FOUND, "unknown profile "+body.ProfileID)
+		return
+	}
+
+	backups, err := getProfileBackups(body.ProfileID)
+	if err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not read the backups")
+		return
+	}
+	sendResponse(c, backups)
+}
+
+func adminProfileBackupRestore(c *gin.Context) {
+	body := getBody[AdminBackupRequest](c)
+	if _, ok := getProfile(body.ProfileID); !ok {
+		sendError(c, BACKEND_NOT_FOUND, "unknown profile "+body.ProfileID)
+		return
+	}
+
+	if err := restoreProfileBackup(body.ProfileID, body.Backup); err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, err.Error())
+		return
+	}
+	sendResponse(c, true)
+}
+
+// backupsCommand lists the backups of a profile, or restores one of them
+// while the server is stopped:
+//
+//	MT-GO backups <profile id> [backup]
+func backupsCommand(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("usage: backups <profile id> [backup]")
+	}
+
+	initializeDatabaseMaps(&Database)
+	if err := setServerConfigCore(&Database.core, tools.ReadFile); err != nil {
+		return err
+	}
+	if err := setProfiles(&Database); err != nil {
+		return err
+	}
+
+	profileID := args[0]
+	if _, ok := getProfile(profileID); !ok {
+		return fmt.Errorf("unknown profile %s", profileID)
+	}
+
+	if len(args) == 2 {
+		return restoreProfileBackup(profileID, args[1])
+	}
+
+	backups, err := getProfileBackups(profileID)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("Profile %s has no backups\n", profileID)
+	}
+	for _, backup := range backups {
+		fmt.Println(backup)
+	}
+	return nil
+}
+
+type AdminResetRequest struct {
+	ProfileID   string   `json:"profileId"`
+	AllProfiles bool     `json:"allProfiles"`
+	Parts       []string `json:"parts"`
+}
+
+func adminProfileReset(c *gin.Context) {
+	body := getBody[AdminResetRequest](c)
+	if err := validateResetParts(body.Parts); err != nil {
+		sendError(c, BACKEND_BAD_REQUEST, err.Error())
+		return
+	}
+
+	if body.AllProfiles {
+		summaries, err := resetProfiles(body.Parts)
+		if err != nil {
+			sendError(c, BACKEND_UNKNOWN_ERROR, err.Error())
+			return
+		}
+		sendResponse(c, summaries)
+		return
+	}
+
+	if _, ok := getProfile(body.ProfileID); !ok {
+		sendError(c, BACKEND_NOT_FOUND, "unknown profile "+body.ProfileID)
+		return
+	}
+
+	summary, err := resetProfile(body.ProfileID, body.Parts)
+	if err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, err.Error())
+		return
+	}
+	sendResponse(c, []*ProfileResetSummary{summary})
+}
+
+func adminProfileExport(c *gin.Context) {
+	body := getBody[AdminProfileRequest](c)
+	archive, err := exportProfile(body.ProfileID)
+	if err != nil {
+		sendError(c, BACKEND_NOT_FOUND, err.Error())
+		return
+	}
+	sendResponse(c, archive)
+}
+
+func adminProfileImport(c *gin.Context) {
+	archive := getBody[ProfileArchive](c)
+	profileID, err := importProfile(archive)
+	if err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_BAD_REQUEST, err.Error())
+		return
+	}
+	sendResponse(c, profileID)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// PROFILE_ARCHIVE_VERSION is the format of exported profiles. Bump it when the
+// archive layout changes; character changes are covered by schemaVersion.
+const PROFILE_ARCHIVE_VERSION = 1
+
+// ProfileArchive is a whole profile in one file, to move it between servers
+type ProfileArchive struct {
+	Version       int                         `json:"version"`
+	SchemaVersion int                         `json:"schemaVersion"`
+	ExportedAt    int64                       `json:"exportedAt"`
+	ProfileID     string                      `json:"profileId"`
+	Files         profileDocuments            `json:"files"`
+	Raid          RaidProfileJSON             `json:"raid"`
+	Purchases     map[string]*TraderPurchases `json:"purchases,omitempty"`
+}
+
+// toProfileDocument converts a profile file to generic JSON
+func toProfileDocument(data interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeProfileDocument(encoded)
+}
+
+// exportProfile returns the archive of a profile as it is in memory, including
+// changes not yet saved
+func exportProfile(profileID string) (*ProfileArchive, error) {
+	profile, ok := lockProfile(profileID)
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %s", profileID)
+	}
+	defer profile.lock.Unlock()
+	if profile.account == nil {
+		return nil, fmt.Errorf("profile %s has no account", profileID)
+	}
+
+	archive := &ProfileArchive{
+		Version:       PROFILE_ARCHIVE_VERSION,
+		SchemaVersion: profile.account.SchemaVersion,
+		ExportedAt:    time.Now().Unix(),
+		ProfileID:     profileID,
+		Files:         make(profileDocuments),
+		Raid:          raidProfileToJSON(profile.raid),
+		Purchases:     profile.purchases,
+	}
+
+	files := map[string]interface{}{
+		ACCOUNT_FILE:   profile.account,
+		STORAGE_FILE:   profile.storage,
+		DIALOGUES_FILE: profile.dialogues,
+	}
+	if profile.character != nil {
+		files[CHARACTER_FILE] = profile.character
+	}
+	for file, data := range files {
+		if data == nil {
+			continue
+		}
+		document, err := toProfileDocument(data)
+		if err != nil {
+			return nil, fmt.Errorf("error exporting %s of profile %s: %w", file, profileID, err)
+		}
+		archive.Files[file] = document
+	}
+	return archive, nil
+}
+
+// remapProfileID replaces the profile id, and the character ids derived from
+// it, everywhere they identify the profile
+func remapProfileID(documents profileDocuments, from string, to string) {
+	remap := func(document map[string]interface{}, key string) {
+		value, ok := document[key].(string)
+		if !ok {
+			return
+		}
+		for _, prefix := range []string{"", "pmc", "scav"} {
+			if value == prefix+from {
+				document[key] = prefix + to
+				return
+			}
+		}
+	}
+
+	remap(documents[ACCOUNT_FILE], "id")
+	remap(documents[STORAGE_FILE], "_id")
+	if character, ok := documents[CHARACTER_FILE]; ok {
+		remap(character, "_id")
+		remap(character, "aid")
+		remap(character, "savage")
+	}
+}
+
+// decodeProfileDocuments decodes archive documents into a profile, reporting
+// the file and path of any value that does not fit
+func decodeProfileDocuments(documents profileDocuments) (*ProfileStruct, error) {
+	profile := &ProfileStruct{
+		account:   &structs.Account{},
+		storage:   &structs.Storage{},
+		dialogues: make(structs.Dialogues),
+	}
+
+	targets := map[string]interface{}{
+		ACCOUNT_FILE:   profile.account,
+		STORAGE_FILE:   profile.storage,
+		DIALOGUES_FILE: &profile.dialogues,
+	}
+	if document, ok := documents[CHARACTER_FILE]; ok && len(document) != 0 {
+		profile.character = &structs.Character{}
+		targets[CHARACTER_FILE] = profile.character
+	}
+
+	for file, target := range targets {
+		document, ok := documents[file]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := tools.UnmarshalInto(file, "", data, target); err != nil {
+			return nil, err
+		}
+	}
+	return profile, nil
+}
+
+// importProfile validates an archive, migrates it to the current schema and
+// registers it as a new profile. A profile id already in use is replaced by a
+// fresh one. Callers must hold databaseLock.
+func importProfile(archive *ProfileArchive) (string, error) {
+	if archive.Version != PROFILE_ARCHIVE_VERSION {
+		return "", fmt.Errorf("unsupported profile archive version %d, expected %d", archive.Version, PROFILE_ARCHIVE_VERSION)
+	}
+	documents := archive.Files
+	if _, ok := documents[ACCOUNT_FILE]; !ok {
+		return "", fmt.Errorf("profile archive has no %s", ACCOUNT_FILE)
+	}
+
+	from := getDocumentSchemaVersion(documents)
+	if from > getProfileSchemaVersion() {
+		return "", fmt.Errorf("profile archive has schema version %d, newer than this server's %d", from, getProfileSchemaVersion())
+	}
+	applied, err := applyProfileMigrations(documents, from)
+	if err != nil {
+		return "", fmt.Errorf("error migrating profile archive: %w", err)
+	}
+
+	profileID, _ := documents[ACCOUNT_FILE]["id"].(string)
+	if profileID == "" {
+		return "", fmt.Errorf("profile archive has no account id")
+	}
+	// the id names the profile folder, so it must not be able to point elsewhere
+	if !tools.IsMongoId(profileID) {
+		return "", fmt.Errorf("profile archive has invalid account id %q", profileID)
+	}
+
+	// held until the profile is in Database.profiles, like registering one,
+	// so neither another import nor a register can claim the id or names
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+
+	store, err := getProfileStore()
+	if err != nil {
+		return "", err
+	}
+	exists, err := store.Exists(profileID)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := getProfile(profileID); ok || exists {
+		newID := tools.GenerateMongoId()
+		log.Printf("Profile id %s is in use, importing as %s", profileID, newID)
+		remapProfileID(documents, profileID, newID)
+		profileID = newID
+	}
+
+	profile, err := decodeProfileDocuments(documents)
+	if err != nil {
+		return "", fmt.Errorf("invalid profile archive: %w", err)
+	}
+	if profile.account.Username == "" {
+		return "", fmt.Errorf("profile archive has no username")
+	}
+
+	if _, ok := getProfileByUsername(profile.account.Username); ok {
+		return "", fmt.Errorf("username %s is taken", profile.account.Username)
+	}
+	if profile.character != nil && isNicknameTaken(profile.character.Info.Nickname, profileID) {
+		return "", fmt.Errorf("nickname %s is taken", profile.character.Info.Nickname)
+	}
+	profile.raid = raidProfileFromJSON(archive.Raid)
+	profile.purchases = archive.Purchases
+	if profile.purchases == nil {
+		profile.purchases = make(map[string]*TraderPurchases)
+	}
+
+	files := make(map[string]interface{}, len(profileFileNames))
+	for _, file := range profileFileNames {
+		files[file.name] = documents[file.name]
+		if file.file == PROFILE_RAID {
+			files[file.name] = archive.Raid
+		} else if file.file == PROFILE_PURCHASES {
+			files[file.name] = profile.purchases
+		} else if documents[file.name] == nil {
+			files[file.name] = map[string]interface{}{}
+		}
+	}
+	if err := writeProfileFiles(profileID, files); err != nil {
+		return "", err
+	}
+
+	profilesLock.Lock()
+	Database.profiles[profileID] = profile
+	profilesLock.Unlock()
+	setProfileNickname(profileID, getCharacterNickname(profile))
+
+	if len(applied) != 0 {
+		log.Printf("Migrated imported profile %s: %s", profileID, strings.Join(applied, ", "))
+	}
+	log.Printf("Imported profile %s (%s)", profileID, profile.account.Username)
+	return profileID, nil
+}
+
+// readProfileArchive reads an archive file written by exportCommand
+func readProfileArchive(path string) (*ProfileArchive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile archive: %w", err)
+	}
+
+	archive := &ProfileArchive{}
+	if err := tools.UnmarshalInto(path, "", data, archive); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// exportCommand writes a profile to an archive file while the server is stopped:
+//
+//	MT-GO export <profile id> <file>
+func exportCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: export <profile id> <file>")
+	}
+
+	initializeDatabaseMaps(&Database)
+	if err := readProfiles(&Database); err != nil {
+		return err
+	}
+
+	archive, err := exportProfile(args[0])
+	if err != nil {
+		return err
+	}
+	if err := tools.WriteToFile(args[1], tools.Stringify(archive, false)); err != nil {
+		return fmt.Errorf("error writing profile archive: %w", err)
+	}
+	log.Printf("Exported profile %s to %s", args[0], args[1])
+	return nil
+}
+
+// importCommand registers a profile from an archive file while the server is stopped:
+//
+//	MT-GO import <file>
+func importCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: import <file>")
+	}
+
+	archive, err := readProfileArchive(args[0])
+	if err != nil {
+		return err
+	}
+
+	initializeDatabaseMaps(&Database)
+	if err := readProfiles(&Database); err != nil {
+		return err
+	}
+
+	_, err = importProfile(archive)
+	return err
+}
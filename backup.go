@@ -0,0 +1,222 @@
+package main
+
+import (
+	"MT-GO/tools"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	BACKUPS_DIRECTORY  string = "backups"
+	BACKUP_TIME_FORMAT string = "2006-01-02_15-04-05.000"
+
+	DEFAULT_BACKUP_COUNT    = 5
+	DEFAULT_BACKUP_INTERVAL = time.Hour
+)
+
+// profileWriteLock serializes writing profile folders, so a save that was
+// serialized before a restore cannot overwrite the restored files
+var profileWriteLock sync.Mutex
+
+type BackupConfigStruct struct {
+	count    int
+	interval time.Duration
+}
+
+// getBackupConfig returns the "backups" settings of server.json: how many
+// backups to keep per profile and how many seconds apart they are taken. A
+// count of 0 disables backups. Callers must hold databaseLock.
+func getBackupConfig() BackupConfigStruct {
+	config := BackupConfigStruct{count: DEFAULT_BACKUP_COUNT, interval: DEFAULT_BACKUP_INTERVAL}
+	backups, ok := Database.core.serverConfig["backups"].(map[string]interface{})
+	if !ok {
+		return config
+	}
+	if count, ok := backups["count"].(float64); ok && count >= 0 {
+		config.count = int(count)
+	}
+	if seconds, ok := backups["interval"].(float64); ok && seconds >= 0 {
+		config.interval = time.Duration(seconds * float64(time.Second))
+	}
+	return config
+}
+
+// getProfileBackups returns the backups of a profile, newest first
+func getProfileBackups(profileID string) ([]string, error) {
+	store, err := getProfileStore()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := store.Backups(profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, err := time.Parse(BACKUP_TIME_FORMAT, name); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// backupProfile copies the saved files of a profile into a new timestamped backup
+func backupProfile(profileID string) (string, error) {
+	store, err := getProfileStore()
+	if err != nil {
+		return "", err
+	}
+
+	files, err := readProfileFiles(func(file string) ([]byte, error) {
+		return store.Read(profileID, file)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error backing up profile %s: %w", profileID, err)
+	}
+	return writeProfileBackup(store, profileID, files)
+}
+
+// writeProfileBackup saves files already read from a profile as a new
+// timestamped backup of it
+func writeProfileBackup(store ProfileStore, profileID string, files map[string][]byte) (string, error) {
+	backup := time.Now().UTC().Format(BACKUP_TIME_FORMAT)
+	if err := store.WriteBackup(profileID, backup, files); err != nil {
+		return "", fmt.Errorf("error backing up profile %s: %w", profileID, err)
+	}
+	return backup, nil
+}
+
+// pruneProfileBackups removes the oldest backups of a profile past count
+func pruneProfileBackups(profileID string, count int) error {
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+
+	backups, err := getProfileBackups(profileID)
+	if err != nil {
+		return err
+	}
+	for i := count; i < len(backups); i++ {
+		if err := store.RemoveBackup(profileID, backups[i]); err != nil {
+			return fmt.Errorf("error removing backup %s of profile %s: %w", backups[i], profileID, err)
+		}
+	}
+	return nil
+}
+
+// backupProfileIfDue backs up a profile if its newest backup is older than
+// the configured interval
+func backupProfileIfDue(profileID string, config BackupConfigStruct) {
+	if config.count == 0 {
+		return
+	}
+
+	backups, err := getProfileBackups(profileID)
+	if err != nil {
+		log.Printf("Error reading backups of profile %s: %v", profileID, err)
+		return
+	}
+	if len(backups) != 0 {
+		newest, _ := time.Parse(BACKUP_TIME_FORMAT, backups[0])
+		if time.Since(newest) < config.interval {
+			return
+		}
+	}
+
+	if _, err := backupProfile(profileID); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := pruneProfileBackups(profileID, config.count); err != nil {
+		log.Println(err)
+	}
+}
+
+// restoreProfileBackup replaces a profile, in memory and in the store, with
+// one of its backups. The current files are backed up first so a restore can
+// be undone. It waits for requests using the profile to finish; later ones
+// get the restored profile. Callers must hold databaseLock.
+func restoreProfileBackup(profileID string, backup string) error {
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+
+	backups, err := getProfileBackups(profileID)
+	if err != nil {
+		return fmt.Errorf("error reading backups of profile %s: %w", profileID, err)
+	}
+	found := false
+	for _, name := range backups {
+		found = found || name == backup
+	}
+	if !found {
+		return fmt.Errorf("profile %s has no backup %s", profileID, backup)
+	}
+
+	readBackup := func(file string) ([]byte, error) {
+		return store.ReadBackup(profileID, backup, file)
+	}
+	files, err := readProfileFiles(readBackup)
+	if err != nil {
+		return fmt.Errorf("error reading backup %s of profile %s: %w", backup, profileID, err)
+	}
+	restored := loadProfile(readBackup, profileID)
+	if restored.account == nil {
+		return fmt.Errorf("backup %s of profile %s has no valid account", backup, profileID)
+	}
+
+	config := getBackupConfig()
+
+	current, ok := lockProfile(profileID)
+	if !ok {
+		return fmt.Errorf("unknown profile %s", profileID)
+	}
+	defer current.lock.Unlock()
+
+	// the backup may predate the character, so it can release the nickname
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+
+	profileWriteLock.Lock()
+	defer profileWriteLock.Unlock()
+
+	if _, err := backupProfile(profileID); err != nil {
+		return err
+	}
+	// buy limits are not rolled back, so a restore cannot be used to buy more
+	restored.purchases = current.purchases
+	files[PURCHASES_FILE] = []byte(tools.Stringify(current.purchases, false))
+	if err := store.Write(profileID, files); err != nil {
+		return fmt.Errorf("error restoring backup %s of profile %s: %w", backup, profileID, err)
+	}
+
+	// backups taken before raid state was saved keep the current one
+	if files[RAID_FILE] == nil {
+		restored.raid = current.raid
+	}
+	profilesLock.Lock()
+	Database.profiles[profileID] = restored
+	profilesLock.Unlock()
+
+	setProfileNickname(profileID, getCharacterNickname(restored))
+
+	// the backup taken before restoring is kept even when backups are disabled
+	count := config.count
+	if count < 1 {
+		count = 1
+	}
+	if err := pruneProfileBackups(profileID, count); err != nil {
+		log.Println(err)
+	}
+
+	log.Printf("Restored profile %s from backup %s", profileID, backup)
+	return nil
+}
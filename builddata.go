@@ -0,0 +1,197 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Files that are not part of the repository and are generated by build-data
+const (
+	ITEMS_FILE_PATH        string = "database/items.json"
+	WEAPON_CACHE_FILE_PATH string = BOT_FILE_PATH + "/weaponCache.json"
+)
+
+// missingBuildDataError explains how to generate a build-data file that is absent
+func missingBuildDataError(filePath string) error {
+	return fmt.Errorf("%s is missing, generate it with `MT-GO build-data -items <items dump>`", filePath)
+}
+
+// buildDataCommand generates items.json and weaponCache.json. Items are
+// assembled from a dump given with -items, either a file or a directory of
+// files holding items keyed by id, a single item or a client items response.
+// The weapon cache is generated from the bot loadouts and the core presets.
+// Without a dump or an existing items.json the weapon cache is still written,
+// but the command fails, as the server cannot start without items.
+func buildDataCommand(args []string) error {
+	flags := flag.NewFlagSet("build-data", flag.ContinueOnError)
+	itemsPath := flags.String("items", "", "items dump to import, a file or a directory of files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var items map[string]*structs.DatabaseItem
+	switch {
+	case *itemsPath != "":
+		imported, err := importItems(*itemsPath)
+		if err != nil {
+			return err
+		}
+		if err := tools.WriteToFile(ITEMS_FILE_PATH, tools.Stringify(imported, true)); err != nil {
+			return fmt.Errorf("error writing %s: %w", ITEMS_FILE_PATH, err)
+		}
+		log.Printf("Wrote %d items to %s", len(imported), ITEMS_FILE_PATH)
+		items = imported
+	case tools.FileExist(ITEMS_FILE_PATH):
+		existing, err := tools.ReadParsedMap[structs.DatabaseItem](ITEMS_FILE_PATH)
+		if err != nil {
+			return fmt.Errorf("error reading items.json: %w", err)
+		}
+		log.Printf("Keeping the existing %s", ITEMS_FILE_PATH)
+		items = existing
+	}
+
+	weaponCache, err := buildWeaponCache(items)
+	if err != nil {
+		return err
+	}
+	if err := tools.WriteToFile(WEAPON_CACHE_FILE_PATH, tools.Stringify(weaponCache, false)); err != nil {
+		return fmt.Errorf("error writing %s: %w", WEAPON_CACHE_FILE_PATH, err)
+	}
+	log.Printf("Wrote %d weapons to %s", len(weaponCache), WEAPON_CACHE_FILE_PATH)
+
+	if items == nil {
+		return missingBuildDataError(ITEMS_FILE_PATH)
+	}
+	return nil
+}
+
+// importItems reads every item from a dump file or every .json file of a
+// dump directory
+func importItems(path string) (map[string]*structs.DatabaseItem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading items dump: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		names, err := tools.GetFilesFrom(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading items dump: %w", err)
+		}
+		files = files[:0]
+		for _, name := range names {
+			if strings.HasSuffix(name, ".json") {
+				files = append(files, filepath.Join(path, name))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	items := make(map[string]*structs.DatabaseItem)
+	for _, file := range files {
+		if err := importItemsFile(file, items); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("items dump %s has no items", path)
+	}
+	return items, nil
+}
+
+func importItemsFile(file string, items map[string]*structs.DatabaseItem) error {
+	data, err := tools.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading items dump: %w", err)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+		ID   string          `json:"_id"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return &tools.DecodeError{File: file, Err: err}
+	}
+
+	// a single item
+	if envelope.ID != "" {
+		item := new(structs.DatabaseItem)
+		if err := tools.UnmarshalInto(file, "", data, item); err != nil {
+			return err
+		}
+		items[item.ID] = item
+		return nil
+	}
+
+	// a response from /client/items
+	path := ""
+	if len(envelope.Data) != 0 {
+		data = envelope.Data
+		path = "data"
+	}
+
+	dump := make(map[string]*structs.DatabaseItem)
+	if err := tools.UnmarshalInto(file, path, data, &dump); err != nil {
+		return err
+	}
+	for id, item := range dump {
+		if item.ID != id {
+			return &tools.DecodeError{File: file, Path: id, Err: fmt.Errorf("item is keyed by %s but has _id %s", id, item.ID)}
+		}
+		items[id] = item
+	}
+	return nil
+}
+
+// buildWeaponCache maps every weapon a bot can spawn with to the ids of the
+// presets built on it. Weapons are checked against items unless it is nil.
+// Bot loadouts are read as they are on disk, without mods.
+func buildWeaponCache(items map[string]*structs.DatabaseItem) (map[string][]string, error) {
+	scratch := &DatabaseStruct{}
+	initializeDatabaseMaps(scratch)
+
+	if err := setDatabaseCore(scratch, tools.ReadFile); err != nil {
+		return nil, err
+	}
+	if err := setBots(&scratch.bot, tools.ReadFile); err != nil {
+		return nil, err
+	}
+
+	weaponCache := make(map[string][]string)
+	for botType, bot := range scratch.bot.bots {
+		if bot.loadout == nil {
+			continue
+		}
+
+		weapons := [][]string{bot.loadout.PrimaryWeapon, bot.loadout.SecondaryWeapon, bot.loadout.Holster}
+		for _, slot := range weapons {
+			for _, tpl := range slot {
+				if _, ok := weaponCache[tpl]; ok {
+					continue
+				}
+				if _, ok := items[tpl]; !ok && items != nil {
+					log.Printf("Bot %s has weapon %s, which is not an item", botType, tpl)
+				}
+
+				presetIDs := make([]string, 0, len(scratch.core.presets[tpl]))
+				for id := range scratch.core.presets[tpl] {
+					presetIDs = append(presetIDs, id)
+				}
+				sort.Strings(presetIDs)
+				weaponCache[tpl] = presetIDs
+			}
+		}
+	}
+
+	return weaponCache, nil
+}
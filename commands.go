@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commands are run instead of starting the server when main is given one as
+// its first argument
+var commands = map[string]func(args []string) error{
+	"backups":       backupsCommand,
+	"build-data":    buildDataCommand,
+	"export":        exportCommand,
+	"import":        importCommand,
+	"migrate":       migrateCommand,
+	"migrate-store": migrateStoreCommand,
+	"reset":         resetCommand,
+	"validate":      validateCommand,
+}
+
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for commandName := range commands {
+			names = append(names, commandName)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %s, expected one of: %s", name, strings.Join(names, ", "))
+	}
+	return command(args)
+}
@@ -1,29 +1,39 @@
 package main
 
 import (
+	"MT-GO/structs"
 	"MT-GO/tools"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type DatabaseStruct struct {
 	core          CoreStruct
 	connections   ConnectionStruct
-	items         map[string]interface{}
+	items         map[string]*structs.DatabaseItem
 	locales       LocaleStruct
 	templates     TemplatesStruct
-	traders       map[string]interface{}
+	traders       map[string]*TraderStruct
 	flea          FleaStruct
-	quests        map[string]interface{}
+	quests        map[string]*structs.Quest
 	hideout       HideoutStruct
 	locations     LocationsStruct
 	weather       map[string]interface{}
 	customization map[string]interface{}
-	editions      map[string]interface{}
+	editions      map[string]*EditionStruct
 	bot           BotStruct
-	profiles      map[string]interface{}
+	profiles      map[string]*ProfileStruct
 	//bundles  []map[string]interface{}
 }
 
@@ -33,7 +43,7 @@ type CoreStruct struct {
 	serverConfig   map[string]interface{}
 	globals        map[string]interface{}
 	locations      map[string]interface{}
-	presets        map[string]interface{}
+	presets        map[string]map[string]*structs.ItemPreset
 	//gameplay        map[string]interface{}
 	//blacklist       []interface{}
 	matchMetrics map[string]interface{}
@@ -52,177 +62,245 @@ type FleaStruct struct {
 }
 
 type HideoutStruct struct {
-	areas       []map[string]interface{}
-	productions map[string]interface{}
+	areas       []*structs.HideoutArea
+	productions []*structs.HideoutProduction
 	scavcase    []map[string]interface{}
 	qte         []map[string]interface{}
 	settings    map[string]interface{}
 }
 
 type LocationsStruct struct {
-	locations map[string]interface{}
+	locations map[string]*LocationStruct
 	lootGen   LootGenStruct
 }
 
 var Database = DatabaseStruct{}
 
-func initializeDatabase() error {
-	Database.core = CoreStruct{
+// initializeDatabase loads the whole database, reading database files through
+// the overlay of the enabled mods
+func initializeDatabase(overlay modOverlay) error {
+	initializeDatabaseMaps(&Database)
+
+	if err := setDatabase(&Database, databaseLoaders, overlay.read); err != nil {
+		return fmt.Errorf("error setting database: %w", err)
+	}
+	databaseModOverlay = overlay
+
+	return nil
+}
+
+func initializeDatabaseMaps(db *DatabaseStruct) {
+	db.core = CoreStruct{
 		botTemplate:    make(map[string]interface{}),
 		clientSettings: make(map[string]interface{}),
 		serverConfig:   make(map[string]interface{}),
 		globals:        make(map[string]interface{}),
 		locations:      make(map[string]interface{}),
 		matchMetrics:   make(map[string]interface{}),
-		presets:        make(map[string]interface{}),
+		presets:        make(map[string]map[string]*structs.ItemPreset),
 	}
-	Database.connections = ConnectionStruct{
+	db.connections = ConnectionStruct{
 		webSocket:      make(map[string]interface{}),
 		webSocketPings: make(map[string]interface{}),
 	}
-	Database.items = make(map[string]interface{})
-	Database.locales = LocaleStruct{
+	db.items = make(map[string]*structs.DatabaseItem)
+	db.locales = LocaleStruct{
 		locales:   make(map[string]interface{}),
 		extras:    make(map[string]interface{}),
 		languages: make(map[string]interface{}),
 	}
-	Database.templates = TemplatesStruct{
-		Handbook: HandbookStruct{
-			Items:      []map[string]interface{}{},
-			Categories: []map[string]interface{}{},
+	db.templates = TemplatesStruct{
+		Handbook: structs.Handbook{
+			Items:      []*structs.HandbookItem{},
+			Categories: []*structs.HandbookCategory{},
 		},
-		Prices: make(map[string]interface{}),
+		Prices: make(map[string]float64),
 		TplLookup: TplLookupStruct{
 			Items: ItemsLookupStruct{
-				byId:     make(map[string]interface{}),
-				byParent: make(map[string]interface{}),
+				byId:     make(map[string]float64),
+				byParent: make(map[string][]string),
+				handbook: make(map[string]float64),
 			},
 			Categories: CategoriesLookupStruct{
-				byId:     make(map[string]interface{}),
-				byParent: make(map[string]interface{}),
+				byId:     make(map[string]string),
+				byParent: make(map[string][]string),
 			},
 		},
 	}
-	Database.editions = make(map[string]interface{})
-	Database.traders = make(map[string]interface{})
-	Database.quests = make(map[string]interface{})
-	Database.flea = FleaStruct{
+	db.editions = make(map[string]*EditionStruct)
+	db.traders = make(map[string]*TraderStruct)
+	db.quests = make(map[string]*structs.Quest)
+	db.flea = FleaStruct{
 		offers:           []map[string]interface{}{},
 		offerscount:      0,
 		selectedCategory: "",
 		categories:       make(map[string]interface{}),
 	}
-	Database.hideout = HideoutStruct{
-		areas:       []map[string]interface{}{},
-		productions: make(map[string]interface{}),
+	db.hideout = HideoutStruct{
+		areas:       []*structs.HideoutArea{},
+		productions: []*structs.HideoutProduction{},
 		scavcase:    []map[string]interface{}{},
 		qte:         []map[string]interface{}{},
 		settings:    make(map[string]interface{}),
 	}
-	Database.customization = make(map[string]interface{})
-	Database.profiles = map[string]interface{}{}
-	Database.weather = make(map[string]interface{})
-	Database.bot = BotStruct{
-		bots:        make(map[string]interface{}),
+	db.customization = make(map[string]interface{})
+	db.profiles = make(map[string]*ProfileStruct)
+	db.weather = make(map[string]interface{})
+	db.bot = BotStruct{
+		bots:        make(map[string]*BotTypeStruct),
 		core:        make(map[string]interface{}),
 		names:       make(map[string]interface{}),
 		appearance:  make(map[string]interface{}),
 		playerScav:  make(map[string]interface{}),
 		weaponCache: make(map[string]interface{}),
 	}
-	Database.locations = LocationsStruct{
-		locations: make(map[string]interface{}),
+	db.locations = LocationsStruct{
+		locations: make(map[string]*LocationStruct),
 		lootGen: LootGenStruct{
 			containers: make(map[string]interface{}),
 			static:     make(map[string]interface{}),
 		},
 	}
-
-	if err := setDatabase(); err != nil {
-		return fmt.Errorf("error setting database: %w", err)
-	}
-
-	return nil
 }
 
-func setDatabase() error {
-	if err := setDatabaseCore(); err != nil {
-		return err
-	}
-
-	if err := setEditions(); err != nil {
-		return err
-	}
-
-	if err := setItems(); err != nil {
-		return err
+// databaseLoader is one independent part of the database and the paths it
+// reads. load reads every file through the reader it is given, so the caller
+// decides whether mods are applied.
+type databaseLoader struct {
+	name  string
+	paths []string
+	load  func(*DatabaseStruct, tools.FileReader) error
+	apply func(dst *DatabaseStruct, src *DatabaseStruct)
+}
+
+var databaseLoaders = []databaseLoader{
+	{"core", []string{CORE_FILE_PATH}, setDatabaseCore,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.core = src.core }},
+	{"editions", []string{EDITIONS_FILE_PATH}, setEditions,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.editions = src.editions }},
+	{"items", []string{ITEMS_FILE_PATH}, setItems,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.items = src.items }},
+	{"locales", []string{LOCALES_FILE_PATH}, setLocales,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.locales = src.locales }},
+	{"templates", []string{"database/templates.json", "database/liveflea.json"}, setTemplates,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.templates = src.templates }},
+	{"traders", []string{TRADERS_FILE_PATH}, setTraders,
+		func(dst *DatabaseStruct, src *DatabaseStruct) {
+			alignTraderResupplies(src.traders, time.Now().Unix())
+			dst.traders = src.traders
+		}},
+	{"quests", []string{"database/quests.json"}, setQuests,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.quests = src.quests }},
+	{"hideout", []string{HIDEOUT_FILE_PATH}, setHideout,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.hideout = src.hideout }},
+	{"customization", []string{"database/customization.json"}, setCustomization,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.customization = src.customization }},
+	// profiles are written by the server at runtime, so they are never reloaded from disk
+	{"profiles", []string{PROFILES_FILE_PATH},
+		func(db *DatabaseStruct, _ tools.FileReader) error { return setProfiles(db) }, nil},
+	{"weather", []string{"database/weather.json"}, setWeather,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.weather = src.weather }},
+	{"bot", []string{BOT_FILE_PATH}, setBot,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.bot = src.bot }},
+	{"locations", []string{"database/locations", "database/lootGen"}, setLocations,
+		func(dst *DatabaseStruct, src *DatabaseStruct) { dst.locations = src.locations }},
+}
+
+// getGameDataLoaders returns the loaders of everything but profiles, which
+// only read the database folder
+func getGameDataLoaders() []databaseLoader {
+	loaders := make([]databaseLoader, 0, len(databaseLoaders))
+	for _, loader := range databaseLoaders {
+		if loader.name != "profiles" {
+			loaders = append(loaders, loader)
+		}
 	}
+	return loaders
+}
 
-	if err := setLocales(); err != nil {
-		return err
-	}
+// loaderReport is the outcome of one databaseLoader for the startup report
+type loaderReport struct {
+	name     string
+	duration time.Duration
+	files    int
+	size     int64
+}
 
-	if err := setTemplates(); err != nil {
-		return err
-	}
+// setDatabase runs loaders concurrently, as each one only writes its own part
+// of the Database. The first failure cancels the loaders not yet started.
+func setDatabase(db *DatabaseStruct, loaders []databaseLoader, read tools.FileReader) error {
+	start := time.Now()
+	reports := make([]loaderReport, len(loaders))
 
-	if err := setTraders(); err != nil {
-		return err
-	}
+	group, ctx := errgroup.WithContext(context.Background())
+	group.SetLimit(runtime.NumCPU())
 
-	if err := setQuests(); err != nil {
-		return err
-	}
+	for i, loader := range loaders {
+		i, loader := i, loader
+		group.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
-	if err := setHideout(); err != nil {
-		return err
-	}
+			loaderStart := time.Now()
+			if err := loader.load(db, read); err != nil {
+				return fmt.Errorf("error loading %s: %w", loader.name, err)
+			}
 
-	if err := setCustomization(); err != nil {
-		return err
+			report := loaderReport{name: loader.name, duration: time.Since(loaderStart)}
+			for _, path := range loader.paths {
+				files, size := tools.GetDirectorySize(path)
+				report.files += files
+				report.size += size
+			}
+			reports[i] = report
+			return nil
+		})
 	}
 
-	if err := setProfiles(); err != nil {
+	if err := group.Wait(); err != nil {
 		return err
 	}
 
-	if err := setWeather(); err != nil {
-		return err
-	}
+	logDatabaseReport(reports, time.Since(start))
+	return nil
+}
 
-	if err := setBot(); err != nil {
-		return err
-	}
+func logDatabaseReport(reports []loaderReport, total time.Duration) {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
 
-	if err := setLocations(); err != nil {
-		return err
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Database loaded in %s\n", total.Round(time.Millisecond))
+	for _, report := range reports {
+		fmt.Fprintf(&builder, "  %-14s %8s %6d files %10s\n", report.name, report.duration.Round(time.Millisecond), report.files, tools.FormatBytes(report.size))
 	}
-
-	return nil
+	fmt.Fprintf(&builder, "  heap in use: %s", tools.FormatBytes(int64(memory.HeapInuse)))
+	log.Println(builder.String())
 }
 
-func setDatabaseCore() error {
-	core := &Database.core
+func setDatabaseCore(db *DatabaseStruct, read tools.FileReader) error {
+	core := &db.core
 
-	if err := setServerConfigCore(core); err != nil {
+	if err := setServerConfigCore(core, read); err != nil {
 		return fmt.Errorf("error setting server config: %w", err)
 	}
-	if err := setMatchMetricsCore(core); err != nil {
+	if err := setMatchMetricsCore(core, read); err != nil {
 		return fmt.Errorf("error setting match metrics: %w", err)
 	}
-	if err := setGlobalsCore(core); err != nil {
+	if err := setGlobalsCore(core, read); err != nil {
 		return fmt.Errorf("error setting globals: %w", err)
 	}
 	if err := setPresetsCore(core); err != nil {
 		return fmt.Errorf("error setting presets: %w", err)
 	}
-	if err := setClientSettingsCore(core); err != nil {
+	if err := setClientSettingsCore(core, read); err != nil {
 		return fmt.Errorf("error setting client settings: %w", err)
 	}
-	if err := setLocationsCore(core); err != nil {
+	if err := setLocationsCore(core, read); err != nil {
 		return fmt.Errorf("error setting locations: %w", err)
 	}
-	if err := setBotTemplateCore(core); err != nil {
+	if err := setBotTemplateCore(core, read); err != nil {
 		return fmt.Errorf("error setting bot template: %w", err)
 	}
 
@@ -239,8 +317,8 @@ const (
 	SERVER_CONFIG_PATH     string = CORE_FILE_PATH + "/server.json"
 )
 
-func setServerConfigCore(core *CoreStruct) error {
-	serverConfig, err := tools.ReadParsed(SERVER_CONFIG_PATH)
+func setServerConfigCore(core *CoreStruct, read tools.FileReader) error {
+	serverConfig, err := read.Parsed(SERVER_CONFIG_PATH)
 
 	if err != nil {
 		return fmt.Errorf("error reading server.json: %w", err)
@@ -255,8 +333,8 @@ func setServerConfigCore(core *CoreStruct) error {
 	return nil
 }
 
-func setMatchMetricsCore(core *CoreStruct) error {
-	matchMetrics, err := tools.ReadParsed(MATCH_METRICS_PATH)
+func setMatchMetricsCore(core *CoreStruct, read tools.FileReader) error {
+	matchMetrics, err := read.Parsed(MATCH_METRICS_PATH)
 
 	if err != nil {
 		return fmt.Errorf("error reading matchMetrics.json: %w", err)
@@ -271,8 +349,8 @@ func setMatchMetricsCore(core *CoreStruct) error {
 	return nil
 }
 
-func setGlobalsCore(core *CoreStruct) error {
-	globals, err := tools.ReadParsed(GLOBALS_FILE_PATH)
+func setGlobalsCore(core *CoreStruct, read tools.FileReader) error {
+	globals, err := read.Parsed(GLOBALS_FILE_PATH)
 
 	if err != nil {
 		return fmt.Errorf("error reading globals.json: %w", err)
@@ -293,40 +371,32 @@ func setPresetsCore(core *CoreStruct) error {
 		return fmt.Errorf("error reading ItemPresets from globals")
 	}
 
-	globalPresets, ok := presets.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid data structure in ItemPresets")
+	data, err := json.Marshal(presets)
+	if err != nil {
+		return fmt.Errorf("error reading ItemPresets from globals: %w", err)
 	}
 
-	for id, preset := range globalPresets {
+	globalPresets := make(map[string]*structs.ItemPreset)
+	if err := tools.UnmarshalInto(GLOBALS_FILE_PATH, "ItemPresets", data, &globalPresets); err != nil {
+		return err
+	}
 
-		tpl, ok := preset.(map[string]interface{})["_items"].([]interface{})[0].(map[string]interface{})["_tpl"].(string)
-		if !ok {
+	for id, preset := range globalPresets {
+		if len(preset.Items) == 0 || preset.Items[0].Tpl == "" {
 			return fmt.Errorf("tpl not found in preset %s", id)
 		}
+		tpl := preset.Items[0].Tpl
 
 		if _, ok := core.presets[tpl]; !ok {
-			core.presets[tpl] = map[string]interface{}{}
-		}
-
-		presetId, ok := preset.(map[string]interface{})["_id"].(string)
-		if !ok {
-			return fmt.Errorf("presetId not found in preset %s", id)
+			core.presets[tpl] = make(map[string]*structs.ItemPreset)
 		}
-
-		itemPreset, ok := core.presets[tpl].(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("invalid data structure in ItemPresets")
-		}
-
-		itemPreset[presetId] = preset
-
+		core.presets[tpl][preset.ID] = preset
 	}
 	return nil
 }
 
-func setClientSettingsCore(core *CoreStruct) error {
-	clientSettings, err := tools.ReadParsed(CLIENT_SETTINGS_PATH)
+func setClientSettingsCore(core *CoreStruct, read tools.FileReader) error {
+	clientSettings, err := read.Parsed(CLIENT_SETTINGS_PATH)
 
 	if err != nil {
 		return fmt.Errorf("error reading client.settings.json: %w", err)
@@ -347,8 +417,8 @@ func setClientSettingsCore(core *CoreStruct) error {
 	return nil
 }
 
-func setLocationsCore(core *CoreStruct) error {
-	locations, err := tools.ReadParsed(LOCATIONS_FILE_PATH)
+func setLocationsCore(core *CoreStruct, read tools.FileReader) error {
+	locations, err := read.Parsed(LOCATIONS_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading locations.json: %w", err)
 	}
@@ -362,8 +432,8 @@ func setLocationsCore(core *CoreStruct) error {
 	return nil
 }
 
-func setBotTemplateCore(core *CoreStruct) error {
-	botTemplate, err := tools.ReadParsed(BOT_TEMPLATE_FILE_PATH)
+func setBotTemplateCore(core *CoreStruct, read tools.FileReader) error {
+	botTemplate, err := read.Parsed(BOT_TEMPLATE_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading botTemplate.json: %w", err)
 	}
@@ -380,62 +450,49 @@ func setBotTemplateCore(core *CoreStruct) error {
 const EDITIONS_FILE_PATH string = "database/editions"
 
 type EditionStruct struct {
-	bear    map[string]interface{}
-	usec    map[string]interface{}
-	storage map[string]interface{}
+	bear          *structs.Character
+	usec          *structs.Character
+	storage       *structs.EditionStorage
+	customization map[string]*structs.CharacterCustomization
 }
 
-func setEditions() error {
+const DEFAULT_CUSTOMIZATION_FILE_PATH string = EDITIONS_FILE_PATH + "/defaultCustomization.json"
+
+func setEditions(db *DatabaseStruct, read tools.FileReader) error {
 	editionsDirectory, err := tools.GetDirectoriesFrom(EDITIONS_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading editions directory: %w", err)
 	}
 
+	// the default customization is shared by every edition, keyed by side
+	customization := make(map[string]*structs.CharacterCustomization)
+	if err := read.ParsedInto(DEFAULT_CUSTOMIZATION_FILE_PATH, &customization); err != nil {
+		return fmt.Errorf("error reading defaultCustomization.json: %w", err)
+	}
+
 	for _, edition := range editionsDirectory {
 		editionPath := filepath.Join(EDITIONS_FILE_PATH, edition)
-
-		// Read character_bear.json and store in bear map
-		bearData, err := tools.ReadParsed(filepath.Join(editionPath, "character_bear.json"))
-		if err != nil {
-			return fmt.Errorf("error reading character_bear.json for edition %s: %w", edition, err)
+		editionData := &EditionStruct{
+			bear:          &structs.Character{},
+			usec:          &structs.Character{},
+			storage:       &structs.EditionStorage{},
+			customization: customization,
 		}
 
-		bearMap, ok := bearData.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("invalid data structure in character_bear.json for edition %s", edition)
+		if err := read.ParsedInto(filepath.Join(editionPath, "character_bear.json"), editionData.bear); err != nil {
+			return fmt.Errorf("error reading character_bear.json for edition %s: %w", edition, err)
 		}
 
-		// Read character_usec.json and store in usec map
-		usecData, err := tools.ReadParsed(filepath.Join(editionPath, "character_usec.json"))
-		if err != nil {
+		if err := read.ParsedInto(filepath.Join(editionPath, "character_usec.json"), editionData.usec); err != nil {
 			return fmt.Errorf("error reading character_usec.json for edition %s: %w", edition, err)
 		}
 
-		usecMap, ok := usecData.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("invalid data structure in character_usec.json for edition %s", edition)
-		}
-
-		// Read storage.json and store in storage map
-		storageData, err := tools.ReadParsed(filepath.Join(editionPath, "storage.json"))
-		if err != nil {
+		if err := read.ParsedInto(filepath.Join(editionPath, "storage.json"), editionData.storage); err != nil {
 			return fmt.Errorf("error reading storage.json for edition %s: %w", edition, err)
 		}
 
-		storageMap, ok := storageData.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("invalid data structure in storage.json for edition %s", edition)
-		}
-
-		// Create an EditionStruct for this edition and populate the maps
-		editionData := EditionStruct{
-			bear:    bearMap,
-			usec:    usecMap,
-			storage: storageMap,
-		}
-
-		// Add the editionData to the Database.editions map
-		Database.editions[edition] = editionData
+		// Add the editionData to the editions map
+		db.editions[edition] = editionData
 	}
 	return nil
 }
@@ -452,12 +509,12 @@ type LanguageStruct struct {
 	menu   map[string]interface{}
 }
 
-func setLocales() error {
+func setLocales(db *DatabaseStruct, read tools.FileReader) error {
 	localesDirectory, err := tools.GetDirectoriesFrom(LOCALES_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading locales directory: %w", err)
 	}
-	locales := &Database.locales
+	locales := &db.locales
 
 	for _, locale := range localesDirectory {
 		localePath := filepath.Join(LOCALES_FILE_PATH, locale)
@@ -465,7 +522,7 @@ func setLocales() error {
 		menu := filepath.Join(localePath, "menu.json")
 
 		// Read language.json and store in language map
-		localeData, err := tools.ReadParsed(language)
+		localeData, err := read.Parsed(language)
 		if err != nil {
 			return fmt.Errorf("error reading locale.json for locale %s: %w", locale, err)
 		}
@@ -476,7 +533,7 @@ func setLocales() error {
 		}
 
 		// Read menu.json and store in menu map
-		menuData, err := tools.ReadParsed(menu)
+		menuData, err := read.Parsed(menu)
 		if err != nil {
 			return fmt.Errorf("error reading menu.json for locale %s: %w", locale, err)
 		}
@@ -496,22 +553,22 @@ func setLocales() error {
 		locales.locales[locale] = languageData
 	}
 
-	if err := setLocalesLanguages(locales); err != nil {
+	if err := setLocalesLanguages(locales, read); err != nil {
 		return err
 	}
 
-	if err := setLocalesExtras(locales); err != nil {
+	if err := setLocalesExtras(locales, read); err != nil {
 		return err
 	}
 	return nil
 }
 
-func setLocalesExtras(locales *LocaleStruct) error {
+func setLocalesExtras(locales *LocaleStruct, read tools.FileReader) error {
 	extrasFilePath := filepath.Join(LOCALES_FILE_PATH, "extras.json")
 	if !tools.FileExist(extrasFilePath) {
 		return fmt.Errorf("error reading extras.json")
 	}
-	extras, err := tools.ReadParsed(extrasFilePath)
+	extras, err := read.Parsed(extrasFilePath)
 	if err != nil {
 		return fmt.Errorf("error reading extras.json: %w", err)
 	}
@@ -525,9 +582,9 @@ func setLocalesExtras(locales *LocaleStruct) error {
 	return nil
 }
 
-func setLocalesLanguages(locales *LocaleStruct) error {
+func setLocalesLanguages(locales *LocaleStruct, read tools.FileReader) error {
 	languagesFilePath := filepath.Join(LOCALES_FILE_PATH, "languages.json")
-	languages, err := tools.ReadParsed(languagesFilePath)
+	languages, err := read.Parsed(languagesFilePath)
 	if err != nil {
 		return fmt.Errorf("error reading languages.json: %w", err)
 	}
@@ -546,113 +603,94 @@ func setLocalesLanguages(locales *LocaleStruct) error {
 	return nil
 }
 
-func setItems() error {
-	items, err := tools.ReadParsed("database/items.json")
+func setItems(db *DatabaseStruct, read tools.FileReader) error {
+	if !tools.FileExist(ITEMS_FILE_PATH) {
+		return missingBuildDataError(ITEMS_FILE_PATH)
+	}
 
+	items, err := tools.ReadParsedMapWith[structs.DatabaseItem](read, ITEMS_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading items.json: %w", err)
 	}
 
-	Database.items = items.(map[string]interface{})
+	db.items = items
 	return nil
 }
 
 type TemplatesStruct struct {
-	Handbook  HandbookStruct
-	Prices    map[string]interface{}
+	Handbook  structs.Handbook
+	Prices    map[string]float64
 	TplLookup TplLookupStruct
 }
 
-type HandbookStruct struct {
-	Items      []map[string]interface{}
-	Categories []map[string]interface{}
-}
-
 type TplLookupStruct struct {
 	Items      ItemsLookupStruct
 	Categories CategoriesLookupStruct
 }
 
 type ItemsLookupStruct struct {
-	byId     map[string]interface{}
-	byParent map[string]interface{}
+	byId     map[string]float64
+	byParent map[string][]string
+	// handbook is the handbook price, which traders pay regardless of the flea price
+	handbook map[string]float64
 }
 
 type CategoriesLookupStruct struct {
-	byId     map[string]interface{}
-	byParent map[string]interface{}
+	byId     map[string]string
+	byParent map[string][]string
 }
 
-func setTemplates() error {
-	templates := &Database.templates
+func setTemplates(db *DatabaseStruct, read tools.FileReader) error {
+	templates := &db.templates
 
-	templatesData, err := tools.ReadParsed("database/templates.json")
-	if err != nil {
+	templatesData := struct {
+		Data structs.Handbook `json:"data"`
+	}{}
+	if err := read.ParsedInto("database/templates.json", &templatesData); err != nil {
 		return fmt.Errorf("error reading templates.json: %w", err)
 	}
 
-	handbookData := templatesData.(map[string]interface{})["data"].(map[string]interface{})
-
-	items := tools.TransformInterfaceIntoMappedArray(handbookData["Items"].([]interface{}))
-	categories := tools.TransformInterfaceIntoMappedArray(handbookData["Categories"].([]interface{}))
-
-	setHandbookItems(items, templates)
-	setHandbookCategories(categories, templates)
+	if err := setHandbookItems(templatesData.Data.Items, templates, read); err != nil {
+		return err
+	}
+	setHandbookCategories(templatesData.Data.Categories, templates)
 
 	return nil
 }
 
-func setHandbookCategories(categories []map[string]interface{}, templates *TemplatesStruct) error {
-	templates.Handbook.Categories = tools.AuditArrayCapacity(categories)
+func setHandbookCategories(categories []*structs.HandbookCategory, templates *TemplatesStruct) {
+	templates.Handbook.Categories = categories
 
 	byCategory := &templates.TplLookup.Categories // pointer to the CategoriesLookupStruct
 	for _, category := range templates.Handbook.Categories {
-		Id := category["Id"].(string)
-		ParentId, hasParent := category["ParentId"].(string)
-
-		byCategory.byId[Id] = ParentId
-		if hasParent {
-			if mapSlice, ok := byCategory.byParent[ParentId].([]string); ok {
-				byCategory.byParent[ParentId] = append(mapSlice, Id)
-			} else {
-				byCategory.byParent[ParentId] = []string{Id}
-			}
+		byCategory.byId[category.ID] = category.ParentID
+		if category.ParentID != "" {
+			byCategory.byParent[category.ParentID] = append(byCategory.byParent[category.ParentID], category.ID)
 		}
 	}
-	return nil
 }
 
-func setHandbookItems(items []map[string]interface{}, templates *TemplatesStruct) error {
-	templates.Handbook.Items = tools.AuditArrayCapacity(items)
+func setHandbookItems(items []*structs.HandbookItem, templates *TemplatesStruct, read tools.FileReader) error {
+	templates.Handbook.Items = items
 
-	pricesData, err := tools.ReadParsed("database/liveflea.json")
-	if err != nil {
+	prices := make(map[string]float64)
+	if err := read.ParsedInto("database/liveflea.json", &prices); err != nil {
 		return fmt.Errorf("error reading liveflea.json: %w", err)
 	}
-	prices := pricesData.(map[string]interface{}) // prices is a map[string]interface{}
 
 	byItem := &templates.TplLookup.Items // pointer to the ItemsLookupStruct
 	for _, item := range templates.Handbook.Items {
-		Id := item["Id"].(string)
-		ParentId := item["ParentId"].(string)
-
-		// set the item price to the price from liveflea.json if it exists, otherwise use the item from items.json
-		var price interface{} // store price in this variable
-		if p, ok := prices[Id]; ok {
-			price = p
-		} else {
-			price = item
+		// set the item price to the price from liveflea.json if it exists, otherwise use the handbook price
+		price, ok := prices[item.ID]
+		if !ok {
+			price = item.Price
 		}
-		byItem.byId[Id] = price
-		templates.Prices[Id] = price
+		byItem.byId[item.ID] = price
+		byItem.handbook[item.ID] = item.Price
+		templates.Prices[item.ID] = price
 
 		// add the item to the byParent map
-		if mapSlice, ok := byItem.byParent[ParentId].([]string); ok {
-			// if the key exists, append the value to the slice
-			byItem.byParent[ParentId] = append(mapSlice, Id)
-		} else {
-			byItem.byParent[ParentId] = []string{Id}
-		}
+		byItem.byParent[item.ParentID] = append(byItem.byParent[item.ParentID], item.ID)
 	}
 
 	return nil
@@ -660,13 +698,17 @@ func setHandbookItems(items []map[string]interface{}, templates *TemplatesStruct
 
 const TRADERS_FILE_PATH string = "database/traders"
 
-type AssortStruct struct {
-	items             []map[string]interface{}
-	barter_scheme     map[string]interface{}
-	loyal_level_items map[string]interface{}
+type TraderStruct struct {
+	base *structs.TraderBase
+	// baseAssort is everything the trader sells; each player gets a part of
+	// it from getTraderAssort
+	baseAssort  *structs.Assort
+	questAssort structs.QuestAssort
+	suits       []*structs.TraderSuit
+	dialogue    structs.TraderDialogue
 }
 
-func setTraders() error {
+func setTraders(db *DatabaseStruct, read tools.FileReader) error {
 	tradersDirectory, err := tools.GetDirectoriesFrom(TRADERS_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading traders directory: %w", err)
@@ -676,56 +718,55 @@ func setTraders() error {
 		traderID := tradersDirectory[i]
 		traderPath := filepath.Join(TRADERS_FILE_PATH, traderID)
 
-		trader := make(map[string]interface{})
+		trader := &TraderStruct{
+			base:       &structs.TraderBase{},
+			baseAssort: &structs.Assort{},
+		}
+
+		if err := read.ParsedInto(filepath.Join(traderPath, "base.json"), trader.base); err != nil {
+			return fmt.Errorf("error reading base.json for trader %s: %w", traderID, err)
+		}
 
-		for _, file := range []string{"base.json", "assort.json", "questassort.json", "suits.json", "dialogue.json"} {
-			if parsed, err := tools.ReadParsed(filepath.Join(traderPath, file)); err != nil {
+		// the remaining files are optional, but must be well formed when present
+		optional := []struct {
+			file   string
+			target interface{}
+		}{
+			{"assort.json", trader.baseAssort},
+			{"questassort.json", &trader.questAssort},
+			{"suits.json", &trader.suits},
+			{"dialogue.json", &trader.dialogue},
+		}
+		for _, entry := range optional {
+			file, target := entry.file, entry.target
+			filePath := filepath.Join(traderPath, file)
+			if !tools.FileExist(filePath) {
 				continue
-				//return fmt.Errorf("error reading %s for trader %s: %w", file, traderID, err)
-			} else {
-				fileName := strings.TrimSuffix(file, ".json")
-
-				if file == "assort.json" {
-					assort := parsed.(map[string]interface{})
-					trader["assort"] = AssortStruct{}
-					trader["baseAssort"] = AssortStruct{
-						items:             tools.TransformInterfaceIntoMappedArray(assort["items"].([]interface{})),
-						barter_scheme:     assort["barter_scheme"].(map[string]interface{}),
-						loyal_level_items: assort["loyal_level_items"].(map[string]interface{}),
-					}
-				} else if file == "suits.json" {
-					trader["suits"] = tools.TransformInterfaceIntoMappedArray(parsed.([]interface{}))
-				} else {
-					trader[fileName] = parsed.(map[string]interface{})
-				}
+			}
+			if err := read.ParsedInto(filePath, target); err != nil {
+				return fmt.Errorf("error reading %s for trader %s: %w", file, traderID, err)
 			}
 		}
 
-		Database.traders[traderID] = trader
+		db.traders[traderID] = trader
 	}
 
 	return nil
 }
 
-func setQuests() error {
-	quests, err := tools.ReadParsed("database/quests.json")
-
+func setQuests(db *DatabaseStruct, read tools.FileReader) error {
+	quests, err := tools.ReadParsedMapWith[structs.Quest](read, "database/quests.json")
 	if err != nil {
 		return fmt.Errorf("error reading quests.json: %w", err)
 	}
 
-	questsMap, ok := quests.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid data structure in quests.json")
-	}
-
-	Database.quests = questsMap
+	db.quests = quests
 	return nil
 }
 
 const HIDEOUT_FILE_PATH string = "database/hideout"
 
-func setHideout() error {
+func setHideout(db *DatabaseStruct, read tools.FileReader) error {
 	hideoutFiles, err := tools.GetFilesFrom(HIDEOUT_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading hideout directory: %w", err)
@@ -735,29 +776,45 @@ func setHideout() error {
 		fileName := strings.TrimSuffix(file, ".json")
 		filePath := filepath.Join(HIDEOUT_FILE_PATH, file)
 
-		if data, err := tools.ReadParsed(filePath); err != nil {
-			return fmt.Errorf("error reading %s: %w", file, err)
-		} else {
-			switch fileName {
-			case "areas":
-				Database.hideout.areas = tools.TransformInterfaceIntoMappedArray(data.([]interface{}))
-			case "productions":
-				Database.hideout.productions = data.(map[string]interface{})
-			case "scavcase":
-				Database.hideout.scavcase = tools.TransformInterfaceIntoMappedArray(data.([]interface{}))
-			case "qte":
-				Database.hideout.qte = tools.TransformInterfaceIntoMappedArray(data.([]interface{}))
-			case "settings":
-				Database.hideout.settings = data.(map[string]interface{})
+		switch fileName {
+		case "areas":
+			areas, err := tools.ReadParsedSliceWith[structs.HideoutArea](read, filePath)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %w", file, err)
+			}
+			db.hideout.areas = areas
+		case "productions":
+			productions := struct {
+				Data []*structs.HideoutProduction `json:"data"`
+			}{}
+			if err := read.ParsedInto(filePath, &productions); err != nil {
+				return fmt.Errorf("error reading %s: %w", file, err)
 			}
+			db.hideout.productions = productions.Data
+		case "scavcase", "qte":
+			var data []map[string]interface{}
+			if err := read.ParsedInto(filePath, &data); err != nil {
+				return fmt.Errorf("error reading %s: %w", file, err)
+			}
+			if fileName == "scavcase" {
+				db.hideout.scavcase = data
+			} else {
+				db.hideout.qte = data
+			}
+		case "settings":
+			data := make(map[string]interface{})
+			if err := read.ParsedInto(filePath, &data); err != nil {
+				return fmt.Errorf("error reading %s: %w", file, err)
+			}
+			db.hideout.settings = data
 		}
 	}
 
 	return nil
 }
 
-func setCustomization() error {
-	customization, err := tools.ReadParsed("database/customization.json")
+func setCustomization(db *DatabaseStruct, read tools.FileReader) error {
+	customization, err := read.Parsed("database/customization.json")
 	if err != nil {
 		return fmt.Errorf("error reading customization.json: %w", err)
 	}
@@ -767,7 +824,7 @@ func setCustomization() error {
 		return fmt.Errorf("customization.json has invalid structure")
 	}
 
-	Database.customization = data
+	db.customization = data
 	return nil
 }
 
@@ -775,11 +832,15 @@ const USER_FILE_PATH string = "user"
 const PROFILES_FILE_PATH string = USER_FILE_PATH + "/profiles"
 
 type ProfileStruct struct {
-	account   map[string]interface{}
-	character map[string]interface{}
-	storage   map[string]interface{}
-	dialogues map[string]interface{}
+	// lock is held by whatever reads or changes the profile, see lockProfile
+	lock      sync.Mutex
+	account   *structs.Account
+	character *structs.Character
+	storage   *structs.Storage
+	dialogues structs.Dialogues
 	raid      RaidProfileStruct
+	purchases map[string]*TraderPurchases
+	dirty     profileFile
 }
 type RaidProfileStruct struct {
 	lastLocation RaidLocationStruct
@@ -790,57 +851,97 @@ type RaidLocationStruct struct {
 	insurance bool
 }
 
-func setProfiles() error {
-	if !tools.FileExist(USER_FILE_PATH) || !tools.FileExist(PROFILES_FILE_PATH) {
-		if err := tools.CreateDirectory(PROFILES_FILE_PATH); err != nil {
-			return fmt.Errorf("error creating profiles directory: %w", err)
+// setProfiles loads every profile, migrating those saved with an older schema
+// and removing files left by writes that never finished
+func setProfiles(db *DatabaseStruct) error {
+	return loadProfiles(db, true)
+}
+
+// readProfiles loads every profile as it is saved, without migrating or
+// writing any of them, for commands that only read profiles
+func readProfiles(db *DatabaseStruct) error {
+	return loadProfiles(db, false)
+}
+
+// loadProfiles loads every profile. migrate is set by the server, which owns
+// the store and so may also change it while loading.
+func loadProfiles(db *DatabaseStruct, migrate bool) error {
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+
+	if filesystem, ok := store.(*filesystemProfileStore); ok && migrate {
+		if err := filesystem.removeTempFiles(); err != nil {
+			return fmt.Errorf("error removing unfinished writes: %w", err)
 		}
-		return nil
 	}
 
-	profilesDirectory, err := tools.GetDirectoriesFrom(PROFILES_FILE_PATH)
+	profileIDs, err := store.List()
 	if err != nil {
-		return fmt.Errorf("error reading user directory: %w", err)
-	} else if profilesDirectory == nil {
-		log.Printf("No profiles found in %s", PROFILES_FILE_PATH)
+		return fmt.Errorf("error reading profiles: %w", err)
+	} else if len(profileIDs) == 0 {
+		log.Printf("No profiles found")
 		return nil
 	}
 
-	for _, profileID := range profilesDirectory {
-		profilePath := filepath.Join(PROFILES_FILE_PATH, profileID)
-		profile := ProfileStruct{
-			account:   setAccount(profilePath, profileID),
-			character: setCharacter(profilePath, profileID),
-			storage:   setStorage(profilePath, profileID),
-			dialogues: setDialogues(profilePath, profileID),
-			raid: RaidProfileStruct{
-				lastLocation: RaidLocationStruct{
-					name:      "",
-					insurance: false,
-				},
-				carExtracts: 0,
-			},
+	for _, profileID := range profileIDs {
+		if migrate {
+			result, err := migrateProfile(profileID, false)
+			if err != nil {
+				log.Printf("Error migrating profile %s, it will not be loaded: %v", profileID, err)
+				continue
+			} else if result != nil {
+				logMigration(result)
+			}
 		}
-		Database.profiles[profileID] = profile
+
+		db.profiles[profileID] = loadProfile(func(file string) ([]byte, error) {
+			return store.Read(profileID, file)
+		}, profileID)
+	}
+
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+	for profileID, profile := range db.profiles {
+		setProfileNickname(profileID, getCharacterNickname(profile))
 	}
 	return nil
 }
 
-func setAccount(path string, profileID string) map[string]interface{} {
-	accountPath := filepath.Join(path, "account.json")
-	data, err := tools.ReadParsed(accountPath)
+// profileFileReader reads one file of a profile, from the store or a backup
+type profileFileReader func(file string) ([]byte, error)
+
+// loadProfile decodes the files of a profile. Files that are missing or
+// invalid are logged and left empty.
+func loadProfile(read profileFileReader, profileID string) *ProfileStruct {
+	return &ProfileStruct{
+		account:   setAccount(read, profileID),
+		character: setCharacter(read, profileID),
+		storage:   setStorage(read, profileID),
+		dialogues: setDialogues(read, profileID),
+		raid:      setRaid(read, profileID),
+		purchases: setPurchases(read, profileID),
+	}
+}
+
+// readProfileFileInto decodes a file of a profile into v
+func readProfileFileInto(read profileFileReader, profileID string, file string, v interface{}) error {
+	data, err := read(file)
 	if err != nil {
-		log.Printf("Error reading account.json for profile %s: %v", profileID, err)
-		return nil
+		return err
 	}
+	return tools.UnmarshalInto(profileID+"/"+file, "", data, v)
+}
 
-	account, ok := data.(map[string]interface{})
-	if !ok {
-		log.Printf("Account.json for profile %s has invalid structure", profileID)
+func setAccount(read profileFileReader, profileID string) *structs.Account {
+	account := &structs.Account{}
+	if err := readProfileFileInto(read, profileID, ACCOUNT_FILE, account); err != nil {
+		log.Printf("Error reading account.json for profile %s: %v", profileID, err)
 		return nil
 	}
 
-	if len(account) == 0 {
+	if account.ID == "" {
 		log.Printf("Account.json for profile %s is empty", profileID)
 		return nil
 	}
@@ -848,74 +949,46 @@ func setAccount(path string, profileID string) map[string]interface{} {
 	return account
 }
 
-func setCharacter(path string, profileID string) map[string]interface{} {
-	characterPath := filepath.Join(path, "character.json")
-	data, err := tools.ReadParsed(characterPath)
-	if err != nil {
-		log.Printf("Error reading character.json for profile %s: %v", profileID, err)
+func setCharacter(read profileFileReader, profileID string) *structs.Character {
+	character := &structs.Character{}
+	err := readProfileFileInto(read, profileID, CHARACTER_FILE, character)
+	if errors.Is(err, fs.ErrNotExist) {
 		return nil
-	}
-
-	character, ok := data.(map[string]interface{})
-	if !ok {
-		log.Printf("Character.json for profile %s has invalid structure", profileID)
+	} else if err != nil {
+		log.Printf("Error reading character.json for profile %s: %v", profileID, err)
 		return nil
 	}
 
-	if len(character) == 0 {
-		log.Printf("Character.json for profile %s is empty", profileID)
+	// accounts hold an empty character until the client creates one
+	if character.ID == "" {
 		return nil
 	}
 
 	return character
 }
 
-func setStorage(path string, profileID string) map[string]interface{} {
-	storagePath := filepath.Join(path, "storage.json")
-	data, err := tools.ReadParsed(storagePath)
-	if err != nil {
+func setStorage(read profileFileReader, profileID string) *structs.Storage {
+	storage := &structs.Storage{}
+	if err := readProfileFileInto(read, profileID, STORAGE_FILE, storage); err != nil {
 		log.Printf("Error reading storage.json for profile %s: %v", profileID, err)
 		return nil
 	}
 
-	storage, ok := data.(map[string]interface{})
-	if !ok {
-		log.Printf("Storage.json for profile %s has invalid structure", profileID)
-		return nil
-	}
-
-	if len(storage) == 0 {
-		log.Printf("Storage.json for profile %s is empty", profileID)
-		return nil
-	}
-
 	return storage
 }
 
-func setDialogues(path string, profileID string) map[string]interface{} {
-	dialoguesPath := filepath.Join(path, "dialogues.json")
-	data, err := tools.ReadParsed(dialoguesPath)
-	if err != nil {
+func setDialogues(read profileFileReader, profileID string) structs.Dialogues {
+	dialogues := make(structs.Dialogues)
+	if err := readProfileFileInto(read, profileID, DIALOGUES_FILE, &dialogues); err != nil {
 		log.Printf("Error reading dialogues.json for profile %s: %v", profileID, err)
 		return nil
 	}
 
-	dialogues, ok := data.(map[string]interface{})
-	if !ok {
-		log.Printf("Dialogues.json for profile %s has invalid structure", profileID)
-		return nil
-	}
-
-	if len(dialogues) == 0 {
-		log.Printf("Dialogues.json for profile %s is empty", profileID)
-		return nil
-	}
-
 	return dialogues
 }
 
-func setWeather() error {
-	weather, err := tools.ReadParsed("database/weather.json")
+func setWeather(db *DatabaseStruct, read tools.FileReader) error {
+	weather, err := read.Parsed("database/weather.json")
 	if err != nil {
 		return fmt.Errorf("error reading weather.json: %w", err)
 	}
@@ -925,14 +998,14 @@ func setWeather() error {
 		return fmt.Errorf("invalid data structure in weather.json")
 	}
 
-	Database.weather = wMap
+	db.weather = wMap
 	return nil
 }
 
 const BOT_FILE_PATH string = "database/bot"
 
 type BotStruct struct {
-	bots        map[string]interface{}
+	bots        map[string]*BotTypeStruct
 	core        map[string]interface{}
 	names       map[string]interface{}
 	appearance  map[string]interface{}
@@ -940,30 +1013,30 @@ type BotStruct struct {
 	weaponCache map[string]interface{}
 }
 
-func setBot() error {
-	bot := &Database.bot
+func setBot(db *DatabaseStruct, read tools.FileReader) error {
+	bot := &db.bot
 
-	if err := setBotCore(bot); err != nil {
+	if err := setBotCore(bot, read); err != nil {
 		return err
 	}
 
-	if err := setBotNames(bot); err != nil {
+	if err := setBotNames(bot, read); err != nil {
 		return err
 	}
 
-	if err := setBotAppearance(bot); err != nil {
+	if err := setBotAppearance(bot, read); err != nil {
 		return err
 	}
 
-	if err := setBotPlayerScav(bot); err != nil {
+	if err := setBotPlayerScav(bot, read); err != nil {
 		return err
 	}
 
-	if err := setBotWeaponCache(bot); err != nil {
+	if err := setBotWeaponCache(bot, read); err != nil {
 		return err
 	}
 
-	if err := setBots(bot); err != nil {
+	if err := setBots(bot, read); err != nil {
 		return err
 	}
 
@@ -971,8 +1044,8 @@ func setBot() error {
 
 }
 
-func setBotCore(bot *BotStruct) error {
-	botGlobalSettingsData, err := tools.ReadParsed(filepath.Join(BOT_FILE_PATH, "__BotGlobalSettings.json"))
+func setBotCore(bot *BotStruct, read tools.FileReader) error {
+	botGlobalSettingsData, err := read.Parsed(filepath.Join(BOT_FILE_PATH, "__BotGlobalSettings.json"))
 	if err != nil {
 		return fmt.Errorf("error reading __BotGlobalSettings.json: %w", err)
 	}
@@ -986,8 +1059,8 @@ func setBotCore(bot *BotStruct) error {
 	return nil
 }
 
-func setBotNames(bot *BotStruct) error {
-	names, err := tools.ReadParsed(filepath.Join(BOT_FILE_PATH, "names.json"))
+func setBotNames(bot *BotStruct, read tools.FileReader) error {
+	names, err := read.Parsed(filepath.Join(BOT_FILE_PATH, "names.json"))
 	if err != nil {
 		return fmt.Errorf("error reading names.json: %w", err)
 	}
@@ -1001,8 +1074,8 @@ func setBotNames(bot *BotStruct) error {
 	return nil
 }
 
-func setBotAppearance(bot *BotStruct) error {
-	appearance, err := tools.ReadParsed(filepath.Join(BOT_FILE_PATH, "appearance.json"))
+func setBotAppearance(bot *BotStruct, read tools.FileReader) error {
+	appearance, err := read.Parsed(filepath.Join(BOT_FILE_PATH, "appearance.json"))
 	if err != nil {
 		return fmt.Errorf("error reading appearance.json: %w", err)
 	}
@@ -1016,8 +1089,8 @@ func setBotAppearance(bot *BotStruct) error {
 	return nil
 }
 
-func setBotPlayerScav(bot *BotStruct) error {
-	playerScav, err := tools.ReadParsed(filepath.Join(BOT_FILE_PATH, "playerScav.json"))
+func setBotPlayerScav(bot *BotStruct, read tools.FileReader) error {
+	playerScav, err := read.Parsed(filepath.Join(BOT_FILE_PATH, "playerScav.json"))
 	if err != nil {
 		return fmt.Errorf("error reading playerScav.json: %w", err)
 	}
@@ -1031,8 +1104,12 @@ func setBotPlayerScav(bot *BotStruct) error {
 	return nil
 }
 
-func setBotWeaponCache(bot *BotStruct) error {
-	weaponCache, err := tools.ReadParsed(filepath.Join(BOT_FILE_PATH, "weaponCache.json"))
+func setBotWeaponCache(bot *BotStruct, read tools.FileReader) error {
+	if !tools.FileExist(WEAPON_CACHE_FILE_PATH) {
+		return missingBuildDataError(WEAPON_CACHE_FILE_PATH)
+	}
+
+	weaponCache, err := read.Parsed(WEAPON_CACHE_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading weaponCache.json: %w", err)
 	}
@@ -1049,12 +1126,12 @@ func setBotWeaponCache(bot *BotStruct) error {
 const BOTS_FILE_PATH string = "database/bot/bots"
 
 type BotTypeStruct struct {
-	health     map[string]interface{}
-	loadout    map[string]interface{}
+	health     map[string]*structs.BotHealth
+	loadout    *structs.BotLoadout
 	difficulty map[string]interface{}
 }
 
-func setBots(bot *BotStruct) error {
+func setBots(bot *BotStruct, read tools.FileReader) error {
 	botsFiles, err := tools.GetDirectoriesFrom(BOTS_FILE_PATH)
 	if err != nil {
 		return fmt.Errorf("error reading bots directory: %w", err)
@@ -1063,60 +1140,90 @@ func setBots(bot *BotStruct) error {
 	for _, aiType := range botsFiles {
 		botTypePath := filepath.Join(BOTS_FILE_PATH, aiType)
 
-		bot.bots[aiType] = BotTypeStruct{
-			health:     setBotTypeHealth(botTypePath),
-			loadout:    setBotTypeLoadout(botTypePath),
-			difficulty: setBotTypeDifficulty(botTypePath),
+		health, err := setBotTypeHealth(botTypePath, read)
+		if err != nil {
+			return err
+		}
+
+		loadout, err := setBotTypeLoadout(botTypePath, read)
+		if err != nil {
+			return err
+		}
+
+		difficulty, err := setBotTypeDifficulty(botTypePath, read)
+		if err != nil {
+			return fmt.Errorf("error reading difficulties of bot type %s: %w", aiType, err)
+		}
+
+		bot.bots[aiType] = &BotTypeStruct{
+			health:     health,
+			loadout:    loadout,
+			difficulty: difficulty,
 		}
 	}
 
 	return nil
 }
 
-func setBotTypeHealth(path string) map[string]interface{} {
+var botDifficulties = []string{"easy", "normal", "hard", "impossible"}
+
+// setBotTypeHealth reads health.json, which either holds one set of BodyParts
+// shared by every difficulty or one set per difficulty
+func setBotTypeHealth(path string, read tools.FileReader) (map[string]*structs.BotHealth, error) {
 	healthFilePath := filepath.Join(path, "health.json")
+	if !tools.FileExist(healthFilePath) {
+		return nil, nil
+	}
 
-	healthData, err := tools.ReadParsed(healthFilePath)
-	if err != nil || healthData == nil {
-		return nil
+	data, err := read(healthFilePath)
+	if err != nil {
+		return nil, err
 	}
 
-	if health, ok := healthData.(map[string]interface{}); ok && len(health) == 1 {
-		return health
+	shared := struct {
+		BodyParts json.RawMessage `json:"BodyParts"`
+	}{}
+	if err := tools.UnmarshalInto(healthFilePath, "", data, &shared); err != nil {
+		return nil, err
 	}
 
-	healthMap := make(map[string]interface{})
-	for key, value := range healthData.(map[string]interface{}) {
-		if v, ok := value.(map[string]interface{}); ok {
-			healthMap[key] = v
+	healthMap := make(map[string]*structs.BotHealth)
+	if shared.BodyParts == nil {
+		if err := tools.UnmarshalInto(healthFilePath, "", data, &healthMap); err != nil {
+			return nil, err
 		}
+		return healthMap, nil
 	}
 
-	return healthMap
+	health := &structs.BotHealth{}
+	if err := tools.UnmarshalInto(healthFilePath, "", data, health); err != nil {
+		return nil, err
+	}
+	for _, difficulty := range botDifficulties {
+		healthMap[difficulty] = health
+	}
+	return healthMap, nil
 }
 
-func setBotTypeLoadout(path string) map[string]interface{} {
+func setBotTypeLoadout(path string, read tools.FileReader) (*structs.BotLoadout, error) {
 	loadoutFilePath := filepath.Join(path, "loadout.json")
-
-	loadoutData, err := tools.ReadParsed(loadoutFilePath)
-	if err != nil || loadoutData == nil {
-		return nil
+	if !tools.FileExist(loadoutFilePath) {
+		return nil, nil
 	}
 
-	if loadout, ok := loadoutData.(map[string]interface{}); ok {
-		return loadout
+	loadout := &structs.BotLoadout{}
+	if err := read.ParsedInto(loadoutFilePath, loadout); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return loadout, nil
 }
 
-func setBotTypeDifficulty(path string) map[string]interface{} {
+func setBotTypeDifficulty(path string, read tools.FileReader) (map[string]interface{}, error) {
 	difficultiesPath := filepath.Join(path, "difficulties")
 
 	difficultiesDirectory, err := tools.GetFilesFrom(difficultiesPath)
 	if err != nil {
-		log.Panicf("error reading difficulties directory: %v", err)
-		return nil
+		return nil, fmt.Errorf("error reading difficulties directory: %w", err)
 	}
 
 	difficulties := make(map[string]interface{}, len(difficultiesDirectory))
@@ -1124,18 +1231,17 @@ func setBotTypeDifficulty(path string) map[string]interface{} {
 		difficultyName := strings.TrimSuffix(difficulty, ".json")
 		difficultyPath := filepath.Join(difficultiesPath, difficulty)
 
-		difficultyData, err := tools.ReadParsed(difficultyPath)
+		difficultyData, err := read.Parsed(difficultyPath)
 		if err != nil {
-			log.Panicf("error reading %s: %v", difficulty, err)
-			return nil
+			return nil, fmt.Errorf("error reading %s: %w", difficulty, err)
 		}
 		difficulties[difficultyName] = difficultyData
 	}
-	return difficulties
+	return difficulties, nil
 }
 
 type LocationStruct struct {
-	base                   map[string]interface{}
+	base                   *structs.LocationBase
 	dynamicAvailableSpawns map[string]interface{}
 	lootSpawns             map[string]interface{}
 	//waves                  []map[string]interface{}
@@ -1143,27 +1249,23 @@ type LocationStruct struct {
 	presets map[string]interface{}
 }
 
-func setLocations() error {
+func setLocations(db *DatabaseStruct, read tools.FileReader) error {
 	locationsDirectory, err := tools.GetDirectoriesFrom("database/locations")
 	if err != nil {
 		return fmt.Errorf("error reading locations directory: %w", err)
 	}
 
-	locations := &Database.locations
+	locations := &db.locations
 
 	for _, location := range locationsDirectory {
 		locationPath := filepath.Join("database/locations", location)
 
-		base, err := tools.ReadParsed(filepath.Join(locationPath, "base.json"))
-		if err != nil {
+		base := &structs.LocationBase{}
+		if err := read.ParsedInto(filepath.Join(locationPath, "base.json"), base); err != nil {
 			return fmt.Errorf("error reading base.json for location %s: %w", location, err)
 		}
-		baseMap, ok := base.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("invalid data structure in base.json for location %s", location)
-		}
 
-		dynamicAvailableSpawns, err := tools.ReadParsed(filepath.Join(locationPath, "availableSpawns.json"))
+		dynamicAvailableSpawns, err := read.Parsed(filepath.Join(locationPath, "availableSpawns.json"))
 		if err != nil {
 			return fmt.Errorf("error reading availableSpawns.json for location %s: %w", location, err)
 		}
@@ -1172,29 +1274,42 @@ func setLocations() error {
 			return fmt.Errorf("invalid data structure in availableSpawns.json for location %s", location)
 		}
 
-		locationStruct := LocationStruct{
-			base:                   baseMap,
+		lootSpawns, err := setLootSpawns(locationPath, read)
+		if err != nil {
+			return fmt.Errorf("error reading loot spawns for location %s: %w", location, err)
+		}
+
+		presets, err := setLocationPresets(locationPath, read)
+		if err != nil {
+			return fmt.Errorf("error reading presets for location %s: %w", location, err)
+		}
+
+		locationStruct := &LocationStruct{
+			base:                   base,
 			dynamicAvailableSpawns: dynamicAvailableSpawnsMap,
-			lootSpawns:             setLootSpawns(locationPath),
-			presets:                setLocationPresets(locationPath),
+			lootSpawns:             lootSpawns,
+			presets:                presets,
 		}
 
 		locations.locations[location] = locationStruct
 	}
 
-	if err := setLocationsLootGen(locations); err != nil {
+	if err := setLocationsLootGen(locations, read); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func setLootSpawns(path string) map[string]interface{} {
+func setLootSpawns(path string, read tools.FileReader) (map[string]interface{}, error) {
 	lootSpawnsPath := filepath.Join(path, "lootSpawns")
+	if !tools.FileExist(lootSpawnsPath) {
+		return map[string]interface{}{}, nil
+	}
+
 	lootSpawns, err := tools.GetFilesFrom(lootSpawnsPath)
 	if err != nil {
-		log.Panicf("error reading lootSpawns directory: %v", err)
-		return nil
+		return nil, fmt.Errorf("error reading lootSpawns directory: %w", err)
 	}
 	lootSpawnsMap := make(map[string]interface{}, len(lootSpawns))
 
@@ -1202,51 +1317,46 @@ func setLootSpawns(path string) map[string]interface{} {
 		lootSpawnName := strings.TrimSuffix(lootSpawn, ".json")
 		lootSpawnPath := filepath.Join(lootSpawnsPath, lootSpawn)
 
-		lootSpawnData, err := tools.ReadParsed(lootSpawnPath)
+		lootSpawnData, err := read.Parsed(lootSpawnPath)
 		if err != nil {
-			log.Panicf("error reading %s: %v", lootSpawn, err)
-			return nil
+			return nil, fmt.Errorf("error reading %s: %w", lootSpawn, err)
 		}
 		lootSpawnArray, ok := lootSpawnData.([]interface{})
 		if !ok {
-			log.Panicf("invalid data structure in %s", lootSpawn)
-			return nil
+			return nil, fmt.Errorf("invalid data structure in %s", lootSpawn)
 		}
 
 		lootSpawnMap := tools.TransformInterfaceIntoMappedArray(lootSpawnArray)
 		lootSpawnsMap[lootSpawnName] = lootSpawnMap
 	}
-	return lootSpawnsMap
+	return lootSpawnsMap, nil
 }
 
-func setLocationPresets(path string) map[string]interface{} {
+func setLocationPresets(path string, read tools.FileReader) (map[string]interface{}, error) {
 	presetsPath := filepath.Join(path, "#presets")
 	presets, err := tools.GetFilesFrom(presetsPath)
 	if err != nil {
-		log.Panicf("error reading presets directory: %v", err)
-		return nil
+		return nil, fmt.Errorf("error reading presets directory: %w", err)
 	}
 
 	presetsMap := make(map[string]interface{}, len(presets))
 	for _, preset := range presets {
 		presetName := strings.TrimSuffix(preset, ".json")
 		presetPath := filepath.Join(presetsPath, preset)
-		preset, err := tools.ReadParsed(presetPath)
+		presetData, err := read.Parsed(presetPath)
 		if err != nil {
-			log.Panicf("error reading %s: %v", preset, err)
-			return nil
+			return nil, fmt.Errorf("error reading %s: %w", preset, err)
 		}
 
-		presetMap, ok := preset.(map[string]interface{})
+		presetMap, ok := presetData.(map[string]interface{})
 		if !ok {
-			log.Panicf("invalid data structure in %s", preset)
-			return nil
+			return nil, fmt.Errorf("invalid data structure in %s", preset)
 		}
 
 		presetsMap[presetName] = presetMap
 	}
 
-	return presetsMap
+	return presetsMap, nil
 }
 
 type LootGenStruct struct {
@@ -1254,11 +1364,11 @@ type LootGenStruct struct {
 	static     map[string]interface{}
 }
 
-func setLocationsLootGen(locations *LocationsStruct) error {
+func setLocationsLootGen(locations *LocationsStruct, read tools.FileReader) error {
 	lootGenPath := "database/lootGen"
 	lootGen := &locations.lootGen
 
-	containers, err := tools.ReadParsed(filepath.Join(lootGenPath, "containersSpawnData.json"))
+	containers, err := read.Parsed(filepath.Join(lootGenPath, "containersSpawnData.json"))
 	if err != nil {
 		return fmt.Errorf("error reading containersSpawnData.json: %w", err)
 	}
@@ -1268,7 +1378,7 @@ func setLocationsLootGen(locations *LocationsStruct) error {
 	}
 	lootGen.containers = containersMap
 
-	static, err := tools.ReadParsed(filepath.Join(lootGenPath, "staticWeaponsData.json"))
+	static, err := read.Parsed(filepath.Join(lootGenPath, "staticWeaponsData.json"))
 	if err != nil {
 		return fmt.Errorf("error reading staticWeaponsData.json: %w", err)
 	}
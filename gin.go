@@ -1,20 +1,37 @@
 package main
 
 import (
+	"MT-GO/plugins"
+	"bytes"
 	"compress/zlib"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SHUTDOWN_TIMEOUT is how long in-flight requests get to finish on shutdown
+const SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// setGin serves requests until the server receives an interrupt or SIGTERM
 func setGin() error {
 	r := gin.New()
+	r.Use(databaseSnapshot())
+	r.Use(jsonContentTypeParser())
 	setGinRoutes(r)
+	if err := plugins.RoutesRegistered(r); err != nil {
+		return err
+	}
 
 	portFloat, ok := Database.core.serverConfig["port"].(float64)
 	if !ok {
@@ -27,72 +44,237 @@ func setGin() error {
 		return fmt.Errorf("invalid ip address")
 	}
 
-	ipport := net.JoinHostPort(ip, port)
-	return r.Run(ipport)
+	server := &http.Server{
+		Addr:    net.JoinHostPort(ip, port),
+		Handler: r,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
 }
 
 func setGinRoutes(r *gin.Engine) {
-	//mtga := r.Group("/")
+	admin := r.Group("/admin", adminOnly())
+	{
+		admin.POST("/profile/backups", bodyParser[AdminBackupRequest](), adminProfileBackups)
+		admin.POST("/profile/backups/restore", bodyParser[AdminBackupRequest](), adminProfileBackupRestore)
+		admin.POST("/profile/reset", bodyParser[AdminResetRequest](), adminProfileReset)
+		admin.POST("/profile/export", bodyParser[AdminProfileRequest](), adminProfileExport)
+		admin.POST("/profile/import", bodyParser[ProfileArchive](), adminProfileImport)
+	}
+
+	launcher := r.Group("/launcher")
+	{
+		launcher.POST("/server/connect", launcherServerConnect)
+		launcher.POST("/profile/register", bodyParser[LauncherLoginRequest](), launcherProfileRegister)
+		launcher.POST("/profile/login", bodyParser[LauncherLoginRequest](), launcherProfileLogin)
+		launcher.POST("/profile/remove", bodyParser[LauncherLoginRequest](), launcherProfileRemove)
+		launcher.POST("/profile/change/password", bodyParser[LauncherChangePasswordRequest](), launcherProfileChangePassword)
+	}
+
+	client := r.Group("/client")
+	{
+		client.POST("/game/start", mainGameStart)
+		client.POST("/game/version/validate", bodyParser[GameVersionValidateRequest](), mainGameVersionValidate)
+		client.POST("/game/config", sessionProfile(), mainGameConfig)
+		client.POST("/game/keepalive", mainGameKeepAlive)
+		client.POST("/game/logout", mainGameLogout)
+		client.POST("/game/profile/list", sessionProfile(), mainGameProfileList)
+		client.POST("/game/profile/create", bodyParser[ProfileCreateRequest](), sessionProfile(), mainGameProfileCreate)
+		client.POST("/game/profile/items/moving", bodyParser[ItemEventRequest](), sessionProfile(), mainGameProfileItemsMoving)
+		client.POST("/game/profile/select", bodyParser[ProfileSelectRequest](), mainGameProfileSelect)
+		client.POST("/game/profile/nickname/reserved", mainNicknameReserved)
+		client.POST("/profile/status", mainProfileStatus)
+		client.POST("/checkVersion", mainCheckVersion)
+		client.POST("/server/list", mainServerList)
+
+		client.POST("/languages", mainLanguages)
+		client.POST("/menu/locale/:lang", mainMenuLocale)
+		client.POST("/locale/:lang", mainLocale)
+
+		client.POST("/items", mainItems)
+		client.POST("/handbook/templates", mainHandbookTemplates)
+		client.POST("/globals", mainGlobals)
+		client.POST("/settings", mainSettings)
+		client.POST("/customization", mainCustomization)
+		client.POST("/account/customization", mainAccountCustomization)
+		client.POST("/trading/api/traderSettings", mainTraderSettings)
+		client.POST("/trading/api/getTraderAssort/:traderId", sessionProfile(), mainTraderAssort)
+		client.POST("/weather", mainWeather)
+		client.POST("/locations", mainLocations)
+		client.POST("/getMetricsConfig", mainMetricsConfig)
+		client.POST("/raid/configuration", bodyParser[RaidConfigurationRequest](), sessionProfile(), mainRaidConfiguration)
+
+		client.POST("/hideout/areas", mainHideoutAreas)
+		client.POST("/hideout/production/recipes", mainHideoutProductions)
+		client.POST("/hideout/production/scavcase/recipes", mainHideoutScavcase)
+		client.POST("/hideout/settings", mainHideoutSettings)
+		client.POST("/hideout/qte/list", mainHideoutQTE)
+
+		client.POST("/quest/list", mainQuestList)
+
+		client.POST("/friend/list", mainFriendList)
+		client.POST("/friend/request/list/inbox", mainFriendRequestList)
+		client.POST("/friend/request/list/outbox", mainFriendRequestList)
+		client.POST("/mail/dialog/list", mainMailDialogList)
+		client.POST("/match/group/current", mainGroupCurrent)
+		client.POST("/notifier/channel/create", mainNotifierChannelCreate)
+	}
 }
 
-// jsonContentTypeParser parses the body of a request and sets it to the context.
+const (
+	RAW_BODY_KEY string = "rawBody"
+	BODY_KEY     string = "body"
+	PROFILE_KEY  string = "profile"
+)
+
+// jsonContentTypeParser reads the body of a request, inflating it if needed,
+// and sets the raw JSON to the context for bodyParser to decode.
 func jsonContentTypeParser() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Request.Method != "GET" {
+		if c.Request.Method == http.MethodGet || c.Request.Body == nil {
 			c.Next()
+			return
 		}
 
-		userAgent := c.Request.Header.Get("User-Agent")
-
-		if strings.Contains(userAgent, "Unity") {
-			err := parseUnityBody(c)
-			if err != nil {
-				c.AbortWithError(http.StatusBadRequest, err)
-				return
-			}
+		var body []byte
+		var err error
+		if isUnityClient(c) {
+			body, err = parseUnityBody(c)
 		} else {
-			err := parseNormalBody(c)
-			if err != nil {
-				c.AbortWithError(http.StatusBadRequest, err)
-				return
-			}
+			body, err = parseNormalBody(c)
+		}
+		if err != nil {
+			sendError(c, BACKEND_BAD_REQUEST, err.Error())
+			return
 		}
 
+		if len(body) != 0 && !json.Valid(body) {
+			sendError(c, BACKEND_BAD_REQUEST, "malformed json body")
+			return
+		}
+
+		c.Set(RAW_BODY_KEY, body)
 		c.Next()
 	}
 }
 
-// parseUnityBody parses the body of a request from the Unity client.
-func parseUnityBody(c *gin.Context) error {
-	reader, err := zlib.NewReader(c.Request.Body)
+// parseUnityBody parses the body of a request from the Unity client. Some
+// requests are sent uncompressed even from Unity, so those are passed through.
+func parseUnityBody(c *gin.Context) ([]byte, error) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error read body: %w", err)
+	}
+	defer c.Request.Body.Close()
+
+	if !isZlibCompressed(data) {
+		return data, nil
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("error zlib reader: %w", err)
+		return nil, fmt.Errorf("error zlib reader: %w", err)
 	}
 	defer reader.Close()
 
-	bufferString, err := io.ReadAll(reader)
+	body, err := io.ReadAll(reader)
 	if err != nil {
-		return fmt.Errorf("error read body: %w", err)
+		return nil, fmt.Errorf("error read body: %w", err)
 	}
-	c.Set("body", bufferString)
-	return nil
+	return body, nil
 }
 
 // parseNormalBody parses the body of a request from a browser or other client.
-func parseNormalBody(c *gin.Context) error {
-	return nil
-
-	/* 	body, err := io.ReadAll(c.Request.Body)
-	   	if err != nil {
-	   		return fmt.Errorf("error read body: %w", err)
-	   	}
-	   	defer c.Request.Body.Close()
-
-	   	normalBody, err := tools.ParseJSON(&body)
-	   	if err != nil {
-	   		return fmt.Errorf("error parse json: %w", err)
-	   	}
-
-	   	c.Set("body", normalBody)
-	   	return nil */
+func parseNormalBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error read body: %w", err)
+	}
+	defer c.Request.Body.Close()
+
+	return body, nil
+}
+
+// isZlibCompressed checks data for a valid zlib header
+func isZlibCompressed(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+	return data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// bodyParser decodes the JSON body set by jsonContentTypeParser into T.
+// An empty body decodes into the zero value of T.
+func bodyParser[T any]() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(T)
+
+		if raw, ok := c.Get(RAW_BODY_KEY); ok {
+			if data, ok := raw.([]byte); ok && len(data) != 0 {
+				if err := json.Unmarshal(data, body); err != nil {
+					sendError(c, BACKEND_BAD_REQUEST, fmt.Sprintf("invalid request body: %v", err))
+					return
+				}
+			}
+		}
+
+		c.Set(BODY_KEY, body)
+		c.Next()
+	}
+}
+
+// getBody returns the body decoded by bodyParser, or the zero value of T if
+// the route did not decode one.
+func getBody[T any](c *gin.Context) *T {
+	if value, ok := c.Get(BODY_KEY); ok {
+		if body, ok := value.(*T); ok {
+			return body
+		}
+	}
+	return new(T)
+}
+
+// sessionProfile locks the profile of the session for the whole request and
+// sets it to the context, so requests of one client that read or change it
+// cannot interleave. Requests of unknown sessions go through without one.
+func sessionProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profile, ok := lockProfile(getSessionID(c))
+		if !ok {
+			c.Next()
+			return
+		}
+		defer profile.lock.Unlock()
+
+		c.Set(PROFILE_KEY, profile)
+		c.Next()
+	}
+}
+
+// getSessionProfile returns the profile locked by sessionProfile, if the
+// session has one
+func getSessionProfile(c *gin.Context) (*ProfileStruct, bool) {
+	if value, ok := c.Get(PROFILE_KEY); ok {
+		if profile, ok := value.(*ProfileStruct); ok {
+			return profile, true
+		}
+	}
+	return nil, false
 }
@@ -5,6 +5,9 @@ go 1.20
 require (
 	github.com/gin-gonic/gin v1.9.0
 	github.com/jaevor/go-nanoid v1.3.0
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/crypto v0.9.0
+	golang.org/x/sync v0.2.0
 )
 
 require (
@@ -25,7 +28,6 @@ require (
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.11 // indirect
 	golang.org/x/arch v0.3.0 // indirect
-	golang.org/x/crypto v0.9.0 // indirect
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
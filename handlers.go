@@ -0,0 +1,394 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const SESSION_COOKIE string = "PHPSESSID"
+
+// getSessionID returns the session id the client sends in the PHPSESSID cookie
+func getSessionID(c *gin.Context) string {
+	sessionID, err := c.Cookie(SESSION_COOKIE)
+	if err != nil {
+		return ""
+	}
+	return sessionID
+}
+
+// getBackendURL returns the http address of the server from server.json
+func getBackendURL() string {
+	ip, _ := Database.core.serverConfig["ip"].(string)
+	port, _ := Database.core.serverConfig["port"].(float64)
+	return "http://" + net.JoinHostPort(ip, strconv.FormatFloat(port, 'f', -1, 64))
+}
+
+// getProfile returns the profile for the session id, if it exists
+func getProfile(sessionID string) (*ProfileStruct, bool) {
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+
+	profile, ok := Database.profiles[sessionID]
+	return profile, ok
+}
+
+type GameVersionValidateRequest struct {
+	Version struct {
+		Major    string `json:"major"`
+		Minor    string `json:"minor"`
+		Game     string `json:"game"`
+		Backend  string `json:"backend"`
+		Taxonomy string `json:"taxonomy"`
+	} `json:"version"`
+	Develop bool `json:"develop"`
+}
+
+type ProfileCreateRequest struct {
+	Side     string `json:"side"`
+	Nickname string `json:"nickname"`
+	HeadID   string `json:"headId"`
+	VoiceID  string `json:"voiceId"`
+}
+
+type RaidConfigurationRequest struct {
+	Location string `json:"location"`
+}
+
+type ProfileSelectRequest struct {
+	UID string `json:"uid"`
+}
+
+func mainGameStart(c *gin.Context) {
+	data := map[string]interface{}{
+		"utc_time": time.Now().Unix(),
+	}
+	sendResponse(c, data)
+}
+
+func mainGameVersionValidate(c *gin.Context) {
+	body := getBody[GameVersionValidateRequest](c)
+	if body.Version.Major == "" {
+		sendError(c, BACKEND_BAD_REQUEST, "missing client version")
+		return
+	}
+	sendResponse(c, nil)
+}
+
+func mainGameConfig(c *gin.Context) {
+	sessionID := getSessionID(c)
+	lang := "en"
+	if profile, ok := getSessionProfile(c); ok {
+		if profile.account != nil && profile.account.Lang != "" {
+			lang = profile.account.Lang
+		}
+	}
+
+	backend := getBackendURL()
+	data := map[string]interface{}{
+		"aid":               sessionID,
+		"lang":              lang,
+		"languages":         Database.locales.languages,
+		"ndaFree":           false,
+		"taxonomy":          6,
+		"activeProfileId":   "pmc" + sessionID,
+		"backend":           map[string]string{"Lobby": backend, "Trading": backend, "Messaging": backend, "Main": backend, "RagFair": backend},
+		"useProtobuf":       false,
+		"utc_time":          time.Now().Unix(),
+		"totalInGame":       0,
+		"reportAvailable":   true,
+		"twitchEventMember": false,
+	}
+	sendResponse(c, data)
+}
+
+func mainGameKeepAlive(c *gin.Context) {
+	data := map[string]interface{}{
+		"msg":      "OK",
+		"utc_time": time.Now().Unix(),
+	}
+	sendResponse(c, data)
+}
+
+func mainGameLogout(c *gin.Context) {
+	data := map[string]interface{}{
+		"status": "ok",
+	}
+	sendResponse(c, data)
+}
+
+func mainGameProfileList(c *gin.Context) {
+	profiles := []interface{}{}
+	if profile, ok := getSessionProfile(c); ok && profile.character != nil {
+		profiles = append(profiles, profile.character)
+	}
+	sendResponse(c, profiles)
+}
+
+func mainGameProfileCreate(c *gin.Context) {
+	profile, ok := getSessionProfile(c)
+	if !ok || profile.account == nil {
+		sendError(c, BACKEND_NOT_AUTHORIZED, "unknown session")
+		return
+	}
+	if profile.character != nil {
+		sendError(c, BACKEND_BAD_REQUEST, "profile already has a character")
+		return
+	}
+
+	body := getBody[ProfileCreateRequest](c)
+	if body.Side != SIDE_BEAR && body.Side != SIDE_USEC {
+		sendError(c, BACKEND_BAD_REQUEST, "unknown side "+body.Side)
+		return
+	}
+	if len(body.Nickname) < 3 {
+		sendError(c, BACKEND_NICKNAME_NOT_VALID, "nickname is too short")
+		return
+	}
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+	if isNicknameTaken(body.Nickname, profile.account.ID) {
+		sendError(c, BACKEND_NICKNAME_NOT_UNIQUE, "nickname "+body.Nickname+" is taken")
+		return
+	}
+	if _, props, ok := getCustomizationProps(body.HeadID, body.Side); !ok || props["BodyPart"] != "Head" {
+		sendError(c, BACKEND_BAD_REQUEST, "unknown head "+body.HeadID)
+		return
+	}
+	if _, _, ok := getCustomizationProps(body.VoiceID, body.Side); !ok {
+		sendError(c, BACKEND_BAD_REQUEST, "unknown voice "+body.VoiceID)
+		return
+	}
+
+	if err := createCharacter(profile, body.Side, body.Nickname, body.HeadID, body.VoiceID); err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not create the character")
+		return
+	}
+
+	data := map[string]interface{}{
+		"uid": profile.character.ID,
+	}
+	sendResponse(c, data)
+}
+
+func mainGameProfileSelect(c *gin.Context) {
+	body := getBody[ProfileSelectRequest](c)
+	if body.UID == "" {
+		sendError(c, BACKEND_BAD_REQUEST, "missing profile uid")
+		return
+	}
+
+	data := map[string]interface{}{
+		"status": "ok",
+	}
+	sendResponse(c, data)
+}
+
+func mainProfileStatus(c *gin.Context) {
+	sessionID := getSessionID(c)
+	data := map[string]interface{}{
+		"maxPveCountExceeded": false,
+		"profiles": []map[string]interface{}{
+			{"profileid": "scav" + sessionID, "profileToken": nil, "status": "Free", "sid": "", "ip": "", "port": 0},
+			{"profileid": "pmc" + sessionID, "profileToken": nil, "status": "Free", "sid": "", "ip": "", "port": 0},
+		},
+	}
+	sendResponse(c, data)
+}
+
+func mainNicknameReserved(c *gin.Context) {
+	sendResponse(c, "")
+}
+
+func mainCheckVersion(c *gin.Context) {
+	data := map[string]interface{}{
+		"isvalid":       true,
+		"latestVersion": "",
+	}
+	sendResponse(c, data)
+}
+
+func mainServerList(c *gin.Context) {
+	ip, _ := Database.core.serverConfig["ip"].(string)
+	port, _ := Database.core.serverConfig["port"].(float64)
+	data := []map[string]interface{}{
+		{"ip": ip, "port": port},
+	}
+	sendResponse(c, data)
+}
+
+func mainLanguages(c *gin.Context) {
+	sendResponse(c, Database.locales.languages)
+}
+
+// getLanguage returns the locale for lang, falling back to english
+func getLanguage(lang string) LanguageStruct {
+	if language, ok := Database.locales.locales[lang].(LanguageStruct); ok {
+		return language
+	}
+	language, _ := Database.locales.locales["en"].(LanguageStruct)
+	return language
+}
+
+func mainMenuLocale(c *gin.Context) {
+	sendResponse(c, getLanguage(c.Param("lang")).menu)
+}
+
+func mainLocale(c *gin.Context) {
+	sendResponse(c, getLanguage(c.Param("lang")).locale)
+}
+
+func mainItems(c *gin.Context) {
+	sendResponse(c, Database.items)
+}
+
+func mainHandbookTemplates(c *gin.Context) {
+	data := map[string]interface{}{
+		"Items":      Database.templates.Handbook.Items,
+		"Categories": Database.templates.Handbook.Categories,
+	}
+	sendResponse(c, data)
+}
+
+func mainGlobals(c *gin.Context) {
+	data := make(map[string]interface{}, len(Database.core.globals)+1)
+	for key, value := range Database.core.globals {
+		data[key] = value
+	}
+	data["time"] = time.Now().Unix()
+	sendResponse(c, data)
+}
+
+func mainSettings(c *gin.Context) {
+	sendResponse(c, Database.core.clientSettings)
+}
+
+func mainCustomization(c *gin.Context) {
+	sendResponse(c, Database.customization)
+}
+
+func mainAccountCustomization(c *gin.Context) {
+	suits := []interface{}{}
+	for id, customization := range Database.customization {
+		props, ok := customization.(map[string]interface{})["_props"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if sides, ok := props["Side"].([]interface{}); ok && len(sides) > 0 {
+			suits = append(suits, id)
+		}
+	}
+	sendResponse(c, suits)
+}
+
+func mainTraderSettings(c *gin.Context) {
+	traders := make([]*structs.TraderBase, 0, len(Database.traders))
+	for _, trader := range Database.traders {
+		traders = append(traders, trader.base)
+	}
+	sendResponse(c, traders)
+}
+
+func mainWeather(c *gin.Context) {
+	data, ok := Database.weather["data"]
+	if !ok {
+		data = Database.weather
+	}
+	sendResponse(c, data)
+}
+
+func mainLocations(c *gin.Context) {
+	data, ok := Database.core.locations["data"]
+	if !ok {
+		data = Database.core.locations
+	}
+	sendResponse(c, data)
+}
+
+func mainMetricsConfig(c *gin.Context) {
+	sendResponse(c, Database.core.matchMetrics)
+}
+
+func mainHideoutAreas(c *gin.Context) {
+	sendResponse(c, Database.hideout.areas)
+}
+
+func mainHideoutProductions(c *gin.Context) {
+	sendResponse(c, Database.hideout.productions)
+}
+
+func mainHideoutScavcase(c *gin.Context) {
+	sendResponse(c, Database.hideout.scavcase)
+}
+
+func mainHideoutSettings(c *gin.Context) {
+	sendResponse(c, Database.hideout.settings)
+}
+
+func mainHideoutQTE(c *gin.Context) {
+	sendResponse(c, Database.hideout.qte)
+}
+
+func mainQuestList(c *gin.Context) {
+	quests := make([]*structs.Quest, 0, len(Database.quests))
+	for _, quest := range Database.quests {
+		quests = append(quests, quest)
+	}
+	sendResponse(c, quests)
+}
+
+func mainFriendList(c *gin.Context) {
+	data := map[string]interface{}{
+		"Friends":      []interface{}{},
+		"Ignore":       []interface{}{},
+		"InIgnoreList": []interface{}{},
+	}
+	sendResponse(c, data)
+}
+
+func mainFriendRequestList(c *gin.Context) {
+	sendResponse(c, []interface{}{})
+}
+
+func mainMailDialogList(c *gin.Context) {
+	sendResponse(c, []interface{}{})
+}
+
+func mainGroupCurrent(c *gin.Context) {
+	data := map[string]interface{}{
+		"squad": []interface{}{},
+	}
+	sendResponse(c, data)
+}
+
+func mainNotifierChannelCreate(c *gin.Context) {
+	sessionID := getSessionID(c)
+	backend := getBackendURL()
+	data := map[string]interface{}{
+		"server":         backend,
+		"channel_id":     sessionID,
+		"url":            backend + "/notifierServer/get/" + sessionID,
+		"notifierServer": backend + "/notifierServer/get/" + sessionID,
+		"ws":             "ws" + backend[len("http"):] + "/notifierServer/getwebsocket/" + sessionID,
+	}
+	sendResponse(c, data)
+}
+
+func mainRaidConfiguration(c *gin.Context) {
+	profile, ok := getSessionProfile(c)
+	if !ok {
+		sendError(c, BACKEND_NOT_AUTHORIZED, "unknown session")
+		return
+	}
+	body := getBody[RaidConfigurationRequest](c)
+	if err := setLastLocation(profile, body.Location); err != nil {
+		sendError(c, BACKEND_BAD_REQUEST, err.Error())
+		return
+	}
+	sendResponse(c, nil)
+}
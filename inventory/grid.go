@@ -0,0 +1,268 @@
+package inventory
+
+import (
+	"MT-GO/structs"
+	"fmt"
+)
+
+// Grid is the occupancy of one grid of a container, cell by cell
+type Grid struct {
+	Width  int
+	Height int
+	cells  []string
+}
+
+// NewGrid returns an empty grid of width by height cells
+func NewGrid(width int, height int) *Grid {
+	return &Grid{Width: width, Height: height, cells: make([]string, width*height)}
+}
+
+// Fits reports whether a width by height item fits with its top left corner
+// at x, y without leaving the grid or covering another item
+func (g *Grid) Fits(x int, y int, width int, height int) bool {
+	if x < 0 || y < 0 || width < 1 || height < 1 || x+width > g.Width || y+height > g.Height {
+		return false
+	}
+	for row := y; row < y+height; row++ {
+		for column := x; column < x+width; column++ {
+			if g.cells[row*g.Width+column] != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Place marks the cells of the item with id as taken
+func (g *Grid) Place(id string, x int, y int, width int, height int) error {
+	if !g.Fits(x, y, width, height) {
+		return fmt.Errorf("item %s does not fit at %d,%d", id, x, y)
+	}
+	for row := y; row < y+height; row++ {
+		for column := x; column < x+width; column++ {
+			g.cells[row*g.Width+column] = id
+		}
+	}
+	return nil
+}
+
+// At returns the id of the item covering the cell at x, y, if any
+func (g *Grid) At(x int, y int) string {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return ""
+	}
+	return g.cells[y*g.Width+x]
+}
+
+// FindSpace returns the first free position for a width by height item,
+// searching rows from the top and trying each cell unrotated, then rotated
+func (g *Grid) FindSpace(width int, height int) (structs.ItemLocation, bool) {
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.Fits(x, y, width, height) {
+				return structs.ItemLocation{X: x, Y: y, R: 0}, true
+			}
+			if width != height && g.Fits(x, y, height, width) {
+				return structs.ItemLocation{X: x, Y: y, R: 1}, true
+			}
+		}
+	}
+	return structs.ItemLocation{}, false
+}
+
+// Grid returns the occupancy of a grid of a container. Items in ignore are
+// left out, such as one being moved within the same grid.
+func (inv *Inventory) Grid(containerID string, name string, ignore ...string) (*Grid, error) {
+	template, err := inv.Template(containerID)
+	if err != nil {
+		return nil, err
+	}
+	grid, ok := getGrid(template, name)
+	if !ok {
+		return nil, fmt.Errorf("item %s has no grid %s", containerID, name)
+	}
+
+	occupancy := NewGrid(grid.Props.CellsH, grid.Props.CellsV)
+	skip := make(map[string]bool, len(ignore))
+	for _, id := range ignore {
+		skip[id] = true
+	}
+
+	for _, item := range inv.children[containerID] {
+		if item.SlotID != name || skip[item.ID] {
+			continue
+		}
+		if item.Location == nil || item.Location.Index != nil {
+			return nil, fmt.Errorf("item %s in grid %s of %s has no position", item.ID, name, containerID)
+		}
+
+		width, height, err := inv.Size(item.ID)
+		if err != nil {
+			return nil, err
+		}
+		width, height = RotatedSize(width, height, item.Location.R)
+		if err := occupancy.Place(item.ID, item.Location.X, item.Location.Y, width, height); err != nil {
+			return nil, fmt.Errorf("error in grid %s of %s: %w", name, containerID, err)
+		}
+	}
+	return occupancy, nil
+}
+
+// FindSpace returns the first position in a grid of a container where the
+// item with id fits, unrotated or rotated
+func (inv *Inventory) FindSpace(id string, containerID string, name string) (structs.ItemLocation, error) {
+	occupancy, err := inv.Grid(containerID, name, id)
+	if err != nil {
+		return structs.ItemLocation{}, err
+	}
+	width, height, err := inv.Size(id)
+	if err != nil {
+		return structs.ItemLocation{}, err
+	}
+	location, ok := occupancy.FindSpace(width, height)
+	if !ok {
+		return structs.ItemLocation{}, fmt.Errorf("no space for item %s in grid %s of %s", id, name, containerID)
+	}
+	return location, nil
+}
+
+// CanPlace reports why the item with id cannot be put in a grid of a
+// container at location, or nil if it can
+func (inv *Inventory) CanPlace(id string, containerID string, name string, location structs.ItemLocation) error {
+	if err := inv.canEnter(id, containerID); err != nil {
+		return err
+	}
+	template, err := inv.Template(containerID)
+	if err != nil {
+		return err
+	}
+	grid, ok := getGrid(template, name)
+	if !ok {
+		return fmt.Errorf("item %s has no grid %s", containerID, name)
+	}
+	if !inv.allows(grid.Props.Filters, inv.items[id].Tpl) {
+		return fmt.Errorf("item %s is not allowed in grid %s of %s", id, name, containerID)
+	}
+
+	occupancy, err := inv.Grid(containerID, name, id)
+	if err != nil {
+		return err
+	}
+	width, height, err := inv.Size(id)
+	if err != nil {
+		return err
+	}
+	width, height = RotatedSize(width, height, location.R)
+	if !occupancy.Fits(location.X, location.Y, width, height) {
+		return fmt.Errorf("item %s does not fit at %d,%d in grid %s of %s", id, location.X, location.Y, name, containerID)
+	}
+	return nil
+}
+
+// CanAttach reports why the item with id cannot be put in a slot of an item,
+// such as an equipment slot, mod slot or chamber, or nil if it can
+func (inv *Inventory) CanAttach(id string, parentID string, slotID string) error {
+	if err := inv.canEnter(id, parentID); err != nil {
+		return err
+	}
+	template, err := inv.Template(parentID)
+	if err != nil {
+		return err
+	}
+	slot, ok := getSlot(template, slotID)
+	if !ok {
+		return fmt.Errorf("item %s has no slot %s", parentID, slotID)
+	}
+	if !inv.allows(slot.Props.Filters, inv.items[id].Tpl) {
+		return fmt.Errorf("item %s is not allowed in slot %s of %s", id, slotID, parentID)
+	}
+
+	count := 0
+	for _, child := range inv.InSlot(parentID, slotID) {
+		if child.ID != id {
+			count++
+		}
+	}
+	limit := slot.MaxCount
+	if limit < 1 {
+		limit = 1
+	}
+	if count >= limit {
+		return fmt.Errorf("slot %s of %s is taken", slotID, parentID)
+	}
+	return nil
+}
+
+// canEnter checks both items exist and the item is not moved into itself
+func (inv *Inventory) canEnter(id string, parentID string) error {
+	if _, ok := inv.items[id]; !ok {
+		return fmt.Errorf("unknown item %s", id)
+	}
+	if _, ok := inv.items[parentID]; !ok {
+		return fmt.Errorf("unknown item %s", parentID)
+	}
+	if id == parentID || inv.IsInside(parentID, id) {
+		return fmt.Errorf("item %s cannot be put inside itself", id)
+	}
+	return nil
+}
+
+// allows reports whether grid or slot filters accept an item template. An
+// empty filter list accepts anything; otherwise the template or one of its
+// parents must be listed in a filter and not excluded by it.
+func (inv *Inventory) allows(filters []structs.ItemFilter, tpl string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if IsOfAny(inv.templates, tpl, filter.Filter) && !IsOfAny(inv.templates, tpl, filter.ExcludedFilter) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOfAny reports whether an item template, or one of its parents, is in ids
+func IsOfAny(templates map[string]*structs.DatabaseItem, tpl string, ids []string) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+
+	for depth := 0; tpl != "" && depth <= len(templates); depth++ {
+		if set[tpl] {
+			return true
+		}
+		template, ok := templates[tpl]
+		if !ok {
+			return false
+		}
+		tpl = template.Parent
+	}
+	return false
+}
+
+// getGrid returns the grid of a template named name
+func getGrid(template *structs.DatabaseItem, name string) (*structs.ItemGrid, bool) {
+	for i := range template.Props.Grids {
+		if template.Props.Grids[i].Name == name {
+			return &template.Props.Grids[i], true
+		}
+	}
+	return nil, false
+}
+
+// getSlot returns the slot, chamber or cartridge slot of a template named name
+func getSlot(template *structs.DatabaseItem, name string) (*structs.ItemSlot, bool) {
+	for _, slots := range [][]structs.ItemSlot{template.Props.Slots, template.Props.Chambers, template.Props.Cartridges} {
+		for i := range slots {
+			if slots[i].Name == name {
+				return &slots[i], true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,219 @@
+// Package inventory works on the items of a character inventory: the tree
+// formed by their parent and slot links, the cells they take up in a grid,
+// and whether they can be placed in a container.
+package inventory
+
+import (
+	"MT-GO/structs"
+	"fmt"
+)
+
+// Inventory indexes the items of a character inventory by id and by parent.
+// Changes made through it are kept in the CharacterInventory it was built on.
+type Inventory struct {
+	inventory *structs.CharacterInventory
+	templates map[string]*structs.DatabaseItem
+	items     map[string]*structs.InventoryItem
+	children  map[string][]*structs.InventoryItem
+}
+
+// New indexes inventory, looking item templates up in templates. It fails if
+// two items share an id or parent links form a cycle.
+func New(inventory *structs.CharacterInventory, templates map[string]*structs.DatabaseItem) (*Inventory, error) {
+	inv := &Inventory{
+		inventory: inventory,
+		templates: templates,
+		items:     make(map[string]*structs.InventoryItem, len(inventory.Items)),
+		children:  make(map[string][]*structs.InventoryItem),
+	}
+
+	for _, item := range inventory.Items {
+		if _, ok := inv.items[item.ID]; ok {
+			return nil, fmt.Errorf("duplicate item id %s", item.ID)
+		}
+		inv.items[item.ID] = item
+		if item.ParentID != "" {
+			inv.children[item.ParentID] = append(inv.children[item.ParentID], item)
+		}
+	}
+
+	for _, item := range inventory.Items {
+		depth := 0
+		for parent := item.ParentID; parent != ""; depth++ {
+			if depth > len(inventory.Items) {
+				return nil, fmt.Errorf("item %s is its own parent", item.ID)
+			}
+			next, ok := inv.items[parent]
+			if !ok {
+				break
+			}
+			parent = next.ParentID
+		}
+	}
+	return inv, nil
+}
+
+// Item returns the item with id
+func (inv *Inventory) Item(id string) (*structs.InventoryItem, bool) {
+	item, ok := inv.items[id]
+	return item, ok
+}
+
+// Template returns the template of the item with id
+func (inv *Inventory) Template(id string) (*structs.DatabaseItem, error) {
+	item, ok := inv.items[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown item %s", id)
+	}
+	template, ok := inv.templates[item.Tpl]
+	if !ok {
+		return nil, fmt.Errorf("item %s has unknown template %s", id, item.Tpl)
+	}
+	return template, nil
+}
+
+// Children returns the items directly attached to or inside the item with id
+func (inv *Inventory) Children(id string) []*structs.InventoryItem {
+	return append([]*structs.InventoryItem(nil), inv.children[id]...)
+}
+
+// InSlot returns the children of the item with id in a slot or grid
+func (inv *Inventory) InSlot(id string, slotID string) []*structs.InventoryItem {
+	var items []*structs.InventoryItem
+	for _, child := range inv.children[id] {
+		if child.SlotID == slotID {
+			items = append(items, child)
+		}
+	}
+	return items
+}
+
+// Subtree returns the item with id followed by everything attached to or
+// inside it, parents before their children
+func (inv *Inventory) Subtree(id string) []*structs.InventoryItem {
+	item, ok := inv.items[id]
+	if !ok {
+		return nil
+	}
+
+	items := []*structs.InventoryItem{item}
+	for i := 0; i < len(items); i++ {
+		items = append(items, inv.children[items[i].ID]...)
+	}
+	return items
+}
+
+// Root returns the id of the topmost item the item with id is in, such as the
+// stash or equipment
+func (inv *Inventory) Root(id string) string {
+	for {
+		item, ok := inv.items[id]
+		if !ok || item.ParentID == "" {
+			return id
+		}
+		if _, ok := inv.items[item.ParentID]; !ok {
+			return id
+		}
+		id = item.ParentID
+	}
+}
+
+// IsInside reports whether the item with id is attached to or inside, at any
+// depth, the item with ancestorID
+func (inv *Inventory) IsInside(id string, ancestorID string) bool {
+	item, ok := inv.items[id]
+	for ok && item.ParentID != "" {
+		if item.ParentID == ancestorID {
+			return true
+		}
+		item, ok = inv.items[item.ParentID]
+	}
+	return false
+}
+
+// Add adds items, with their parents before their children. Every id must be
+// new and every parent must be in the inventory or among items.
+func (inv *Inventory) Add(items ...*structs.InventoryItem) error {
+	added := make(map[string]bool, len(items))
+	for _, item := range items {
+		if _, ok := inv.items[item.ID]; ok || added[item.ID] {
+			return fmt.Errorf("duplicate item id %s", item.ID)
+		}
+		if _, ok := inv.items[item.ParentID]; !ok && !added[item.ParentID] {
+			return fmt.Errorf("item %s has unknown parent %s", item.ID, item.ParentID)
+		}
+		added[item.ID] = true
+	}
+
+	for _, item := range items {
+		inv.items[item.ID] = item
+		inv.children[item.ParentID] = append(inv.children[item.ParentID], item)
+		inv.inventory.Items = append(inv.inventory.Items, item)
+	}
+	return nil
+}
+
+// Remove removes the item with id and everything attached to or inside it,
+// returning the removed items
+func (inv *Inventory) Remove(id string) []*structs.InventoryItem {
+	removed := inv.Subtree(id)
+	if len(removed) == 0 {
+		return nil
+	}
+
+	ids := make(map[string]bool, len(removed))
+	for _, item := range removed {
+		ids[item.ID] = true
+		delete(inv.items, item.ID)
+		delete(inv.children, item.ID)
+	}
+	inv.unlink(removed[0])
+
+	items := inv.inventory.Items[:0]
+	for _, item := range inv.inventory.Items {
+		if !ids[item.ID] {
+			items = append(items, item)
+		}
+	}
+	for i := len(items); i < len(inv.inventory.Items); i++ {
+		inv.inventory.Items[i] = nil
+	}
+	inv.inventory.Items = items
+	return removed
+}
+
+// Move attaches the item with id to a new parent, slot and location. It does
+// not check the item fits there; see CanPlace and CanAttach.
+func (inv *Inventory) Move(id string, parentID string, slotID string, location *structs.ItemLocation) error {
+	item, ok := inv.items[id]
+	if !ok {
+		return fmt.Errorf("unknown item %s", id)
+	}
+	if _, ok := inv.items[parentID]; !ok {
+		return fmt.Errorf("unknown parent %s", parentID)
+	}
+	if parentID == id || inv.IsInside(parentID, id) {
+		return fmt.Errorf("item %s cannot be moved into itself", id)
+	}
+
+	inv.unlink(item)
+	item.ParentID = parentID
+	item.SlotID = slotID
+	item.Location = location
+	inv.children[parentID] = append(inv.children[parentID], item)
+	return nil
+}
+
+// unlink removes item from the children of its parent
+func (inv *Inventory) unlink(item *structs.InventoryItem) {
+	siblings := inv.children[item.ParentID]
+	for i, sibling := range siblings {
+		if sibling == item {
+			inv.children[item.ParentID] = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(inv.children[item.ParentID]) == 0 {
+		delete(inv.children, item.ParentID)
+	}
+}
@@ -0,0 +1,413 @@
+package inventory
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"path/filepath"
+	"testing"
+)
+
+// TEST_EDITION is the edition whose bear character the tests use, for its
+// weapons with mods and its filled stash
+const TEST_EDITION string = "Edge Of Darkness"
+
+// Items of the bear character of TEST_EDITION
+const (
+	testStash       string = "61b7367440281631fc83f197"
+	testWeapon      string = "5fe49444ae6628187a2e77a1" // equipped, with a magazine
+	testStashWeapon string = "5fe49444ae6628187a2e7899" // in the stash at 0,16
+	testArmor       string = "5fe49444ae6628187a2e77ba" // in the stash at 0,0
+	testVest        string = "5fe49444ae6628187a2e77ab" // a magazine or med kit in each grid
+	testVestMedKit  string = "5fe49444ae6628187a2e77aa" // in grid 4 of testVest
+	testBackpack    string = "5fe49444ae6628187a2e77b1" // equipped
+	testCarKit      string = "5fe49444ae6628187a2e77e9" // in the stash at 8,30, rotated
+	testMagazine    string = "5fe49444ae6628187a2e77a0" // in testWeapon
+)
+
+// Templates of the items above
+const (
+	weaponTpl    string = "5ac4cd105acfc40016339859"
+	muzzleTpl    string = "5ac7655e5acfc40016339a19"
+	handguardTpl string = "5648b1504bdc2d9d488b4584"
+	stockTpl     string = "5ac50c185acfc400163398d4"
+	magazineTpl  string = "55d480c04bdc2d1d4e8b456a"
+	stashTpl     string = "5811ce772459770e9e5f9532"
+	armorTpl     string = "5648a7494bdc2d9d488b4583"
+	vestTpl      string = "5ca20abf86f77418567a43f2"
+	backpackTpl  string = "5ca20d5986f774331e7c9602"
+	carKitTpl    string = "590c661e86f7741e566b646a"
+)
+
+// getTestInventory indexes the inventory of the bear character of
+// TEST_EDITION. items.json is not bundled, so every item gets a 1x1 template
+// except those the tests size in getTestTemplates, which edit may change.
+func getTestInventory(t *testing.T, edit func(templates map[string]*structs.DatabaseItem)) (*Inventory, *structs.CharacterInventory) {
+	t.Helper()
+
+	character := &structs.Character{}
+	path := filepath.Join("..", "database", "editions", TEST_EDITION, "character_bear.json")
+	if err := tools.ReadParsedInto(path, character); err != nil {
+		t.Fatal(err)
+	}
+
+	templates := getTestTemplates()
+	for _, item := range character.Inventory.Items {
+		if _, ok := templates[item.Tpl]; !ok {
+			templates[item.Tpl] = &structs.DatabaseItem{ID: item.Tpl, Props: structs.ItemProps{Width: 1, Height: 1}}
+		}
+	}
+	if edit != nil {
+		edit(templates)
+	}
+
+	inv, err := New(&character.Inventory, templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv, &character.Inventory
+}
+
+// getTestTemplates returns templates for the items the tests size, chosen so
+// the bundled stash layout has no overlaps
+func getTestTemplates() map[string]*structs.DatabaseItem {
+	grid := func(name string, width int, height int) structs.ItemGrid {
+		return structs.ItemGrid{Name: name, Props: structs.ItemGridsProps{CellsH: width, CellsV: height}}
+	}
+	template := func(tpl string, props structs.ItemProps) *structs.DatabaseItem {
+		return &structs.DatabaseItem{ID: tpl, Props: props}
+	}
+
+	templates := []*structs.DatabaseItem{
+		template(weaponTpl, structs.ItemProps{Width: 3, Height: 1, Foldable: true, FoldedSlot: "mod_stock"}),
+		template(muzzleTpl, structs.ItemProps{Width: 1, Height: 1, ExtraSizeLeft: 1}),
+		template(handguardTpl, structs.ItemProps{Width: 1, Height: 1, ExtraSizeLeft: 1}),
+		template(stockTpl, structs.ItemProps{Width: 1, Height: 1, ExtraSizeRight: 1}),
+		template(magazineTpl, structs.ItemProps{Width: 1, Height: 1, ExtraSizeDown: 1}),
+		template(stashTpl, structs.ItemProps{Width: 1, Height: 1, Grids: []structs.ItemGrid{grid("hideout", 10, 68)}}),
+		template(armorTpl, structs.ItemProps{Width: 3, Height: 3}),
+		template(vestTpl, structs.ItemProps{Width: 3, Height: 3, Grids: []structs.ItemGrid{
+			grid("1", 1, 2), grid("2", 1, 2), grid("3", 1, 2), grid("4", 1, 2),
+		}}),
+		template(backpackTpl, structs.ItemProps{Width: 1, Height: 1, Grids: []structs.ItemGrid{grid("main", 3, 3)}}),
+		template(carKitTpl, structs.ItemProps{Width: 2, Height: 1}),
+	}
+
+	byTpl := make(map[string]*structs.DatabaseItem, len(templates))
+	for _, template := range templates {
+		byTpl[template.ID] = template
+	}
+	return byTpl
+}
+
+func TestSize(t *testing.T) {
+	fold := func(inv *structs.CharacterInventory, id string) {
+		for _, item := range inv.Items {
+			if item.ID == id {
+				item.Upd.Foldable.Folded = true
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		edit   func(templates map[string]*structs.DatabaseItem)
+		folded bool
+		width  int
+		height int
+	}{
+		{name: "template size", id: testArmor, width: 3, height: 3},
+		{name: "items inside grids are not mods", id: testVest, width: 3, height: 3},
+		{name: "magazine with cartridges", id: testMagazine, width: 1, height: 1},
+		{name: "mods add the largest size per side", id: testWeapon, width: 5, height: 2},
+		{name: "mods of a stored weapon", id: testStashWeapon, width: 5, height: 2},
+		{
+			name: "forced sizes add to the largest",
+			id:   testWeapon,
+			edit: func(templates map[string]*structs.DatabaseItem) {
+				templates[handguardTpl].Props.ExtraSizeForceAdd = true
+			},
+			width:  6,
+			height: 2,
+		},
+		{name: "folded leaves out the folded slot", id: testWeapon, folded: true, width: 4, height: 2},
+		{
+			name: "folded reduces the width",
+			id:   testWeapon,
+			edit: func(templates map[string]*structs.DatabaseItem) {
+				templates[weaponTpl].Props.SizeReduceRight = 1
+			},
+			folded: true,
+			width:  3,
+			height: 2,
+		},
+		{
+			name: "only foldable items fold",
+			id:   testWeapon,
+			edit: func(templates map[string]*structs.DatabaseItem) {
+				templates[weaponTpl].Props.Foldable = false
+			},
+			folded: true,
+			width:  5,
+			height: 2,
+		},
+		{
+			name: "size is at least one cell",
+			id:   testWeapon,
+			edit: func(templates map[string]*structs.DatabaseItem) {
+				templates[weaponTpl].Props.SizeReduceRight = 10
+			},
+			folded: true,
+			width:  1,
+			height: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inv, items := getTestInventory(t, test.edit)
+			if test.folded {
+				fold(items, test.id)
+			}
+
+			width, height, err := inv.Size(test.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if width != test.width || height != test.height {
+				t.Errorf("got %dx%d, expected %dx%d", width, height, test.width, test.height)
+			}
+		})
+	}
+
+	t.Run("unknown template", func(t *testing.T) {
+		inv, _ := getTestInventory(t, func(templates map[string]*structs.DatabaseItem) {
+			delete(templates, stockTpl)
+		})
+		if _, _, err := inv.Size(testWeapon); err == nil {
+			t.Error("expected an error for a mod without a template")
+		}
+	})
+}
+
+func TestRotatedSize(t *testing.T) {
+	tests := []struct {
+		width, height, r int
+		rotatedWidth     int
+		rotatedHeight    int
+	}{
+		{width: 5, height: 2, r: 0, rotatedWidth: 5, rotatedHeight: 2},
+		{width: 5, height: 2, r: 1, rotatedWidth: 2, rotatedHeight: 5},
+		{width: 1, height: 1, r: 1, rotatedWidth: 1, rotatedHeight: 1},
+	}
+
+	for _, test := range tests {
+		width, height := RotatedSize(test.width, test.height, test.r)
+		if width != test.rotatedWidth || height != test.rotatedHeight {
+			t.Errorf("%dx%d with rotation %d: got %dx%d, expected %dx%d",
+				test.width, test.height, test.r, width, height, test.rotatedWidth, test.rotatedHeight)
+		}
+	}
+}
+
+func TestGridFits(t *testing.T) {
+	grid := NewGrid(4, 3)
+	if err := grid.Place("placed", 1, 1, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		x, y          int
+		width, height int
+		fits          bool
+	}{
+		{name: "free cell", x: 0, y: 0, width: 1, height: 1, fits: true},
+		{name: "whole free row", x: 0, y: 0, width: 4, height: 1, fits: true},
+		{name: "down to the bottom edge", x: 3, y: 0, width: 1, height: 3, fits: true},
+		{name: "past the right edge", x: 3, y: 0, width: 2, height: 1},
+		{name: "past the bottom edge", x: 0, y: 2, width: 1, height: 2},
+		{name: "negative position", x: -1, y: 0, width: 1, height: 1},
+		{name: "empty size", x: 0, y: 0, width: 0, height: 1},
+		{name: "on a placed item", x: 2, y: 1, width: 1, height: 1},
+		{name: "covering a placed item", x: 0, y: 0, width: 4, height: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if fits := grid.Fits(test.x, test.y, test.width, test.height); fits != test.fits {
+				t.Errorf("got %t, expected %t", fits, test.fits)
+			}
+		})
+	}
+
+	if err := grid.Place("other", 2, 0, 1, 2); err == nil {
+		t.Error("expected an error placing an item over another")
+	}
+	if id := grid.At(2, 1); id != "placed" {
+		t.Errorf("cell 2,1 holds %q, expected placed", id)
+	}
+	if id := grid.At(2, 0); id != "" {
+		t.Errorf("cell 2,0 holds %q after a failed place, expected nothing", id)
+	}
+}
+
+func TestGridFindSpace(t *testing.T) {
+	tests := []struct {
+		name          string
+		grid          *Grid
+		placed        [][4]int
+		width, height int
+		location      structs.ItemLocation
+		found         bool
+	}{
+		{name: "empty grid", grid: NewGrid(3, 3), width: 2, height: 2, location: structs.ItemLocation{}, found: true},
+		{
+			name:     "first free row",
+			grid:     NewGrid(3, 3),
+			placed:   [][4]int{{0, 0, 3, 1}},
+			width:    2,
+			height:   1,
+			location: structs.ItemLocation{X: 0, Y: 1},
+			found:    true,
+		},
+		{name: "rotated to fit", grid: NewGrid(1, 3), width: 2, height: 1, location: structs.ItemLocation{R: 1}, found: true},
+		{
+			name:     "unrotated before rotated",
+			grid:     NewGrid(3, 3),
+			placed:   [][4]int{{0, 0, 1, 1}},
+			width:    2,
+			height:   1,
+			location: structs.ItemLocation{X: 1, Y: 0},
+			found:    true,
+		},
+		{name: "too large", grid: NewGrid(2, 2), width: 3, height: 1},
+		{name: "full", grid: NewGrid(2, 2), placed: [][4]int{{0, 0, 2, 2}}, width: 1, height: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, placed := range test.placed {
+				if err := test.grid.Place("placed", placed[0], placed[1], placed[2], placed[3]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			location, found := test.grid.FindSpace(test.width, test.height)
+			if found != test.found {
+				t.Fatalf("got found %t, expected %t", found, test.found)
+			}
+			if found && location != test.location {
+				t.Errorf("got %+v, expected %+v", location, test.location)
+			}
+		})
+	}
+}
+
+func TestInventoryGrid(t *testing.T) {
+	inv, _ := getTestInventory(t, nil)
+	grid, err := inv.Grid(testStash, "hideout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		x, y int
+		id   string
+	}{
+		{name: "top left of an item", x: 0, y: 0, id: testArmor},
+		{name: "bottom right of an item", x: 2, y: 2, id: testArmor},
+		{name: "cell added by a mod", x: 4, y: 17, id: testStashWeapon},
+		{name: "rotated item", x: 8, y: 31, id: testCarKit},
+		{name: "free cell", x: 9, y: 60, id: ""},
+		{name: "outside the grid", x: 10, y: 0, id: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if id := grid.At(test.x, test.y); id != test.id {
+				t.Errorf("cell %d,%d holds %q, expected %q", test.x, test.y, id, test.id)
+			}
+		})
+	}
+
+	if _, err := inv.Grid(testStash, "main"); err == nil {
+		t.Error("expected an error for a grid the stash does not have")
+	}
+}
+
+func TestCanPlace(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		containerID string
+		grid        string
+		location    structs.ItemLocation
+		ok          bool
+	}{
+		{name: "free cells", id: testArmor, containerID: testStash, grid: "hideout", location: structs.ItemLocation{X: 0, Y: 45}, ok: true},
+		{name: "where it already is", id: testArmor, containerID: testStash, grid: "hideout", location: structs.ItemLocation{}, ok: true},
+		{name: "over another item", id: testArmor, containerID: testStash, grid: "hideout", location: structs.ItemLocation{X: 5, Y: 0}},
+		{name: "past the edge", id: testArmor, containerID: testStash, grid: "hideout", location: structs.ItemLocation{X: 8, Y: 45}},
+		{name: "rotated at the edge", id: testWeapon, containerID: testStash, grid: "hideout", location: structs.ItemLocation{X: 8, Y: 45, R: 1}, ok: true},
+		{name: "unrotated at the edge", id: testWeapon, containerID: testStash, grid: "hideout", location: structs.ItemLocation{X: 8, Y: 45}},
+		{name: "rotated over another item", id: testWeapon, containerID: testStash, grid: "hideout", location: structs.ItemLocation{X: 8, Y: 28, R: 1}},
+		{name: "inside itself", id: testVest, containerID: testVest, grid: "1", location: structs.ItemLocation{}},
+		{name: "unknown grid", id: testArmor, containerID: testStash, grid: "main", location: structs.ItemLocation{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inv, _ := getTestInventory(t, nil)
+			err := inv.CanPlace(test.id, test.containerID, test.grid, test.location)
+			if test.ok && err != nil {
+				t.Errorf("expected the item to fit: %v", err)
+			}
+			if !test.ok && err == nil {
+				t.Error("expected the item not to fit")
+			}
+		})
+	}
+}
+
+func TestInventoryFindSpace(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		containerID string
+		grid        string
+		remove      string
+		location    structs.ItemLocation
+		found       bool
+	}{
+		{name: "next free cell", id: testMagazine, containerID: testBackpack, grid: "main", location: structs.ItemLocation{X: 0, Y: 1}, found: true},
+		{name: "wide item in a free row", id: testCarKit, containerID: testBackpack, grid: "main", location: structs.ItemLocation{X: 0, Y: 1}, found: true},
+		{name: "rotated in a narrow grid", id: testCarKit, containerID: testVest, grid: "4", remove: testVestMedKit, location: structs.ItemLocation{R: 1}, found: true},
+		{name: "taken narrow grid", id: testCarKit, containerID: testVest, grid: "4"},
+		{name: "too large", id: testWeapon, containerID: testBackpack, grid: "main"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inv, _ := getTestInventory(t, nil)
+			if test.remove != "" && len(inv.Remove(test.remove)) == 0 {
+				t.Fatalf("unknown item %s", test.remove)
+			}
+
+			location, err := inv.FindSpace(test.id, test.containerID, test.grid)
+			if !test.found {
+				if err == nil {
+					t.Errorf("expected no space, got %+v", location)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if location != test.location {
+				t.Errorf("got %+v, expected %+v", location, test.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"MT-GO/structs"
+	"fmt"
+)
+
+// extraSize is the number of cells mods add on each side of an item
+type extraSize struct {
+	left, right, up, down int
+}
+
+// Size returns the width and height of the item with id in grid cells, before
+// rotation: the size of its template, grown by the mods attached to it and
+// shrunk if it is folded
+func (inv *Inventory) Size(id string) (int, int, error) {
+	item, ok := inv.items[id]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown item %s", id)
+	}
+	template, err := inv.Template(id)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	width, height := template.Props.Width, template.Props.Height
+	if isFolded(item, template) {
+		width -= template.Props.SizeReduceRight
+	}
+
+	// mods that do not force their size only add the largest of them per side
+	var largest, forced extraSize
+	if err := inv.walkMods(item, template, func(mod *structs.DatabaseItem) {
+		props := mod.Props
+		if props.ExtraSizeForceAdd {
+			forced.left += props.ExtraSizeLeft
+			forced.right += props.ExtraSizeRight
+			forced.up += props.ExtraSizeUp
+			forced.down += props.ExtraSizeDown
+			return
+		}
+		if props.ExtraSizeLeft > largest.left {
+			largest.left = props.ExtraSizeLeft
+		}
+		if props.ExtraSizeRight > largest.right {
+			largest.right = props.ExtraSizeRight
+		}
+		if props.ExtraSizeUp > largest.up {
+			largest.up = props.ExtraSizeUp
+		}
+		if props.ExtraSizeDown > largest.down {
+			largest.down = props.ExtraSizeDown
+		}
+	}); err != nil {
+		return 0, 0, err
+	}
+
+	width += largest.left + largest.right + forced.left + forced.right
+	height += largest.up + largest.down + forced.up + forced.down
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height, nil
+}
+
+// RotatedSize returns the size of an item placed with rotation r, where 1 is
+// vertical and 0 horizontal
+func RotatedSize(width int, height int, r int) (int, int) {
+	if r == 1 {
+		return height, width
+	}
+	return width, height
+}
+
+// walkMods calls fn with the template of every mod attached to item, at any
+// depth. Items inside grids are not mods, and a folded item leaves out the
+// mods in its folded slot.
+func (inv *Inventory) walkMods(item *structs.InventoryItem, template *structs.DatabaseItem, fn func(mod *structs.DatabaseItem)) error {
+	folded := isFolded(item, template)
+	for _, child := range inv.children[item.ID] {
+		if isGrid(template, child.SlotID) {
+			continue
+		}
+		if folded && child.SlotID == template.Props.FoldedSlot {
+			continue
+		}
+
+		mod, err := inv.Template(child.ID)
+		if err != nil {
+			return err
+		}
+		fn(mod)
+		if err := inv.walkMods(child, mod, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isFolded reports whether a foldable item is folded
+func isFolded(item *structs.InventoryItem, template *structs.DatabaseItem) bool {
+	return template.Props.Foldable && item.Upd != nil && item.Upd.Foldable != nil && item.Upd.Foldable.Folded
+}
+
+// isGrid reports whether a template has a grid named name
+func isGrid(template *structs.DatabaseItem, name string) bool {
+	_, ok := getGrid(template, name)
+	return ok
+}
@@ -0,0 +1,688 @@
+package main
+
+import (
+	"MT-GO/inventory"
+	"MT-GO/structs"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ItemEventRequest is a batch of actions sent to /client/game/profile/items/moving
+type ItemEventRequest struct {
+	Data   []json.RawMessage `json:"data"`
+	TM     int               `json:"tm"`
+	Reload int               `json:"reload"`
+}
+
+// ItemEventWarning tells the client which action of a batch failed
+type ItemEventWarning struct {
+	Index  int    `json:"index"`
+	ErrMsg string `json:"errmsg"`
+}
+
+// ItemEventResponse is the response to a batch, with the changes it made
+// keyed by character id
+type ItemEventResponse struct {
+	Warnings       []ItemEventWarning         `json:"warnings"`
+	ProfileChanges map[string]*ProfileChanges `json:"profileChanges"`
+}
+
+// ProfileChanges is what a batch changed on a character
+type ProfileChanges struct {
+	ID                    string                         `json:"_id"`
+	Experience            int                            `json:"experience"`
+	Quests                []interface{}                  `json:"quests"`
+	QuestsStatus          []interface{}                  `json:"questsStatus"`
+	RagFairOffers         []interface{}                  `json:"ragFairOffers"`
+	WeaponBuilds          []interface{}                  `json:"weaponBuilds"`
+	EquipmentBuilds       []interface{}                  `json:"equipmentBuilds"`
+	Items                 ItemChanges                    `json:"items"`
+	Production            map[string]interface{}         `json:"production"`
+	Improvements          map[string]interface{}         `json:"improvements"`
+	Skills                structs.CharacterSkills        `json:"skills"`
+	Health                map[string]interface{}         `json:"health"`
+	TraderRelations       map[string]*structs.TraderInfo `json:"traderRelations"`
+	RepeatableQuests      []interface{}                  `json:"repeatableQuests"`
+	RecipeUnlocked        map[string]interface{}         `json:"recipeUnlocked"`
+	ChangedHideoutStashes map[string]interface{}         `json:"changedHideoutStashes"`
+}
+
+// ItemChanges lists the inventory items a batch added, changed and removed.
+// Only the topmost of removed items are listed; the client drops their children.
+type ItemChanges struct {
+	New    []*structs.InventoryItem `json:"new"`
+	Change []*structs.InventoryItem `json:"change"`
+	Del    []ItemDeleted            `json:"del"`
+}
+
+type ItemDeleted struct {
+	ID string `json:"_id"`
+}
+
+// itemEventContext is what the actions of a batch run against. character is
+// a copy that replaces the one of the profile only if every action succeeds.
+type itemEventContext struct {
+	profile   *ProfileStruct
+	character *structs.Character
+	inventory *inventory.Inventory
+	// dirty are profile files besides character.json the batch changed
+	dirty profileFile
+	// committed run once the batch is applied, for effects that cannot be
+	// rolled back such as notifying mods
+	committed []func()
+	// rollback run if the batch fails, to undo changes made outside the
+	// character such as trader stock
+	rollback []func()
+}
+
+// itemEventHandler runs one action of a batch, decoding it from data
+type itemEventHandler func(ctx *itemEventContext, data json.RawMessage) error
+
+// itemEventHandlers maps the Action of each batched action to its handler
+var itemEventHandlers = map[string]itemEventHandler{
+	"Move":             itemEventMove,
+	"Split":            itemEventSplit,
+	"Merge":            itemEventMerge,
+	"Transfer":         itemEventTransfer,
+	"Fold":             itemEventFold,
+	"Toggle":           itemEventToggle,
+	"Tag":              itemEventTag,
+	"Bind":             itemEventBind,
+	"Examine":          itemEventExamine,
+	"ReadEncyclopedia": itemEventReadEncyclopedia,
+	"Remove":           itemEventRemove,
+	"Swap":             itemEventSwap,
+	"TradingConfirm":   itemEventTradingConfirm,
+}
+
+func mainGameProfileItemsMoving(c *gin.Context) {
+	profile, ok := getSessionProfile(c)
+	if !ok || profile.character == nil {
+		sendError(c, BACKEND_NOT_AUTHORIZED, "unknown session")
+		return
+	}
+
+	body := getBody[ItemEventRequest](c)
+	response, err := runItemEvents(profile, body.Data)
+	if err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not run inventory actions")
+		return
+	}
+	sendResponse(c, response)
+}
+
+// runItemEvents runs a batch of actions on a copy of the character of a
+// profile, applying it only if every action succeeds. A failed action is
+// reported as a warning and nothing of the batch is kept. Callers must hold
+// the profile lock.
+func runItemEvents(profile *ProfileStruct, actions []json.RawMessage) (*ItemEventResponse, error) {
+	character, err := cloneCharacter(profile.character)
+	if err != nil {
+		return nil, fmt.Errorf("error copying character of profile %s: %w", profile.account.ID, err)
+	}
+	items, err := inventory.New(&character.Inventory, Database.items)
+	if err != nil {
+		return nil, fmt.Errorf("error reading inventory of profile %s: %w", profile.account.ID, err)
+	}
+	ctx := &itemEventContext{profile: profile, character: character, inventory: items}
+
+	for i, data := range actions {
+		if err := runItemEvent(ctx, data); err != nil {
+			for j := len(ctx.rollback) - 1; j >= 0; j-- {
+				ctx.rollback[j]()
+			}
+			return &ItemEventResponse{
+				Warnings:       []ItemEventWarning{{Index: i, ErrMsg: err.Error()}},
+				ProfileChanges: map[string]*ProfileChanges{},
+			}, nil
+		}
+	}
+
+	changes := getProfileChanges(profile.character, character)
+	profile.character = character
+	markProfileDirty(profile, PROFILE_CHARACTER|ctx.dirty)
+	for _, committed := range ctx.committed {
+		committed()
+	}
+
+	return &ItemEventResponse{
+		Warnings:       []ItemEventWarning{},
+		ProfileChanges: map[string]*ProfileChanges{character.ID: changes},
+	}, nil
+}
+
+func runItemEvent(ctx *itemEventContext, data json.RawMessage) error {
+	var action struct {
+		Action string `json:"Action"`
+	}
+	if err := json.Unmarshal(data, &action); err != nil {
+		return fmt.Errorf("invalid action: %w", err)
+	}
+
+	handler, ok := itemEventHandlers[action.Action]
+	if !ok {
+		return fmt.Errorf("unknown action %s", action.Action)
+	}
+	if err := handler(ctx, data); err != nil {
+		return fmt.Errorf("%s: %w", action.Action, err)
+	}
+	return nil
+}
+
+// getProfileChanges compares a character before and after a batch
+func getProfileChanges(before *structs.Character, after *structs.Character) *ProfileChanges {
+	changes := &ProfileChanges{
+		ID:                    after.ID,
+		Experience:            after.Info.Experience,
+		Quests:                []interface{}{},
+		QuestsStatus:          []interface{}{},
+		RagFairOffers:         []interface{}{},
+		WeaponBuilds:          []interface{}{},
+		EquipmentBuilds:       []interface{}{},
+		Items:                 getItemChanges(before.Inventory.Items, after.Inventory.Items),
+		Improvements:          map[string]interface{}{},
+		Skills:                after.Skills,
+		Health:                after.Health,
+		TraderRelations:       make(map[string]*structs.TraderInfo),
+		RepeatableQuests:      []interface{}{},
+		RecipeUnlocked:        map[string]interface{}{},
+		ChangedHideoutStashes: map[string]interface{}{},
+	}
+
+	for traderID, info := range after.TradersInfo {
+		if info == nil {
+			continue
+		}
+		if previous, ok := before.TradersInfo[traderID]; !ok || previous == nil || *previous != *info {
+			changes.TraderRelations[traderID] = info
+		}
+	}
+	return changes
+}
+
+// getItemChanges returns the items added, changed and removed between two
+// versions of an inventory
+func getItemChanges(before []*structs.InventoryItem, after []*structs.InventoryItem) ItemChanges {
+	changes := ItemChanges{
+		New:    []*structs.InventoryItem{},
+		Change: []*structs.InventoryItem{},
+		Del:    []ItemDeleted{},
+	}
+
+	previous := make(map[string]*structs.InventoryItem, len(before))
+	for _, item := range before {
+		previous[item.ID] = item
+	}
+	current := make(map[string]bool, len(after))
+	for _, item := range after {
+		current[item.ID] = true
+		if old, ok := previous[item.ID]; !ok {
+			changes.New = append(changes.New, item)
+		} else if !reflect.DeepEqual(old, item) {
+			changes.Change = append(changes.Change, item)
+		}
+	}
+
+	for _, item := range before {
+		if current[item.ID] {
+			continue
+		}
+		if _, ok := previous[item.ParentID]; ok && !current[item.ParentID] {
+			continue
+		}
+		changes.Del = append(changes.Del, ItemDeleted{ID: item.ID})
+	}
+	return changes
+}
+
+// ItemEventTarget is where an action puts an item: a grid with a location,
+// or a slot without one
+type ItemEventTarget struct {
+	ID        string                `json:"id"`
+	Container string                `json:"container"`
+	Location  *structs.ItemLocation `json:"location,omitempty"`
+}
+
+type ItemMoveAction struct {
+	Item string          `json:"item"`
+	To   ItemEventTarget `json:"to"`
+}
+
+type ItemSplitAction struct {
+	SplitItem string          `json:"splitItem"`
+	NewItem   string          `json:"newItem"`
+	Container ItemEventTarget `json:"container"`
+	Count     int             `json:"count"`
+}
+
+type ItemMergeAction struct {
+	Item string `json:"item"`
+	With string `json:"with"`
+}
+
+type ItemTransferAction struct {
+	Item  string `json:"item"`
+	With  string `json:"with"`
+	Count int    `json:"count"`
+}
+
+type ItemValueAction struct {
+	Item  string `json:"item"`
+	Value bool   `json:"value"`
+}
+
+type ItemTagAction struct {
+	Item     string `json:"item"`
+	TagName  string `json:"TagName"`
+	TagColor int    `json:"TagColor"`
+}
+
+type ItemBindAction struct {
+	Item  string `json:"item"`
+	Index string `json:"index"`
+}
+
+type ItemExamineAction struct {
+	Item      string `json:"item"`
+	FromOwner *struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"fromOwner,omitempty"`
+}
+
+type ItemReadEncyclopediaAction struct {
+	IDs []string `json:"ids"`
+}
+
+type ItemRemoveAction struct {
+	Item string `json:"item"`
+}
+
+type ItemSwapAction struct {
+	Item  string          `json:"item"`
+	To    ItemEventTarget `json:"to"`
+	Item2 string          `json:"item2"`
+	To2   ItemEventTarget `json:"to2"`
+}
+
+// decodeItemEvent decodes the fields of an action into T
+func decodeItemEvent[T any](data json.RawMessage) (*T, error) {
+	action := new(T)
+	if err := json.Unmarshal(data, action); err != nil {
+		return nil, fmt.Errorf("invalid action: %w", err)
+	}
+	return action, nil
+}
+
+// checkPlacement checks an item fits where it is: at its location in a grid,
+// or in its slot
+func checkPlacement(items *inventory.Inventory, id string) error {
+	item, ok := items.Item(id)
+	if !ok {
+		return fmt.Errorf("unknown item %s", id)
+	}
+	if item.Location != nil && item.Location.Index == nil {
+		return items.CanPlace(id, item.ParentID, item.SlotID, *item.Location)
+	}
+	return items.CanAttach(id, item.ParentID, item.SlotID)
+}
+
+// checkMovable checks an item exists and is not one of the roots of the
+// inventory, such as the stash or equipment, which are never moved or removed
+func checkMovable(ctx *itemEventContext, id string) error {
+	item, ok := ctx.inventory.Item(id)
+	if !ok {
+		return fmt.Errorf("unknown item %s", id)
+	}
+	if item.ParentID == "" {
+		return fmt.Errorf("item %s cannot be moved", id)
+	}
+	roots := &ctx.character.Inventory
+	for _, root := range []string{roots.Equipment, roots.Stash, roots.SortingTable, roots.QuestRaidItems, roots.QuestStashItems} {
+		if id == root {
+			return fmt.Errorf("item %s cannot be moved", id)
+		}
+	}
+	return nil
+}
+
+// moveItem moves an item to target and checks it fits there
+func moveItem(ctx *itemEventContext, id string, target ItemEventTarget) error {
+	if err := checkMovable(ctx, id); err != nil {
+		return err
+	}
+	if err := ctx.inventory.Move(id, target.ID, target.Container, target.Location); err != nil {
+		return err
+	}
+	return checkPlacement(ctx.inventory, id)
+}
+
+// getStackCount returns how many of an item a stack holds
+func getStackCount(item *structs.InventoryItem) int {
+	if item.Upd == nil || item.Upd.StackObjectsCount < 1 {
+		return 1
+	}
+	return item.Upd.StackObjectsCount
+}
+
+func setStackCount(item *structs.InventoryItem, count int) {
+	if item.Upd == nil {
+		item.Upd = &structs.ItemUpd{}
+	}
+	item.Upd.StackObjectsCount = count
+}
+
+// getStackMaxSize returns how many of an item fit in one stack
+func getStackMaxSize(items *inventory.Inventory, id string) (int, error) {
+	template, err := items.Template(id)
+	if err != nil {
+		return 0, err
+	}
+	if template.Props.StackMaxSize < 1 {
+		return 1, nil
+	}
+	return template.Props.StackMaxSize, nil
+}
+
+// getStackPair returns two stacks that can be merged
+func getStackPair(items *inventory.Inventory, id string, withID string) (*structs.InventoryItem, *structs.InventoryItem, error) {
+	item, ok := items.Item(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown item %s", id)
+	}
+	with, ok := items.Item(withID)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown item %s", withID)
+	}
+	if id == withID || item.Tpl != with.Tpl {
+		return nil, nil, fmt.Errorf("items %s and %s do not stack", id, withID)
+	}
+	return item, with, nil
+}
+
+func itemEventMove(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemMoveAction](data)
+	if err != nil {
+		return err
+	}
+	return moveItem(ctx, action.Item, action.To)
+}
+
+func itemEventSplit(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemSplitAction](data)
+	if err != nil {
+		return err
+	}
+	item, ok := ctx.inventory.Item(action.SplitItem)
+	if !ok {
+		return fmt.Errorf("unknown item %s", action.SplitItem)
+	}
+	count := getStackCount(item)
+	if action.Count < 1 || action.Count >= count {
+		return fmt.Errorf("cannot split %d from a stack of %d", action.Count, count)
+	}
+
+	split := &structs.InventoryItem{ID: action.NewItem, Tpl: item.Tpl}
+	if item.Upd != nil {
+		upd := *item.Upd
+		split.Upd = &upd
+	}
+	setStackCount(split, action.Count)
+	setStackCount(item, count-action.Count)
+
+	split.ParentID = action.Container.ID
+	split.SlotID = action.Container.Container
+	split.Location = action.Container.Location
+	if err := ctx.inventory.Add(split); err != nil {
+		return err
+	}
+	return checkPlacement(ctx.inventory, split.ID)
+}
+
+func itemEventMerge(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemMergeAction](data)
+	if err != nil {
+		return err
+	}
+	if err := checkMovable(ctx, action.Item); err != nil {
+		return err
+	}
+	item, with, err := getStackPair(ctx.inventory, action.Item, action.With)
+	if err != nil {
+		return err
+	}
+	maxSize, err := getStackMaxSize(ctx.inventory, with.ID)
+	if err != nil {
+		return err
+	}
+
+	total := getStackCount(item) + getStackCount(with)
+	if total > maxSize {
+		return fmt.Errorf("a stack holds at most %d", maxSize)
+	}
+	setStackCount(with, total)
+	unbindItems(ctx.character, ctx.inventory.Remove(item.ID))
+	return nil
+}
+
+func itemEventTransfer(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemTransferAction](data)
+	if err != nil {
+		return err
+	}
+	item, with, err := getStackPair(ctx.inventory, action.Item, action.With)
+	if err != nil {
+		return err
+	}
+	maxSize, err := getStackMaxSize(ctx.inventory, with.ID)
+	if err != nil {
+		return err
+	}
+
+	count := getStackCount(item)
+	if action.Count < 1 || action.Count >= count {
+		return fmt.Errorf("cannot transfer %d from a stack of %d", action.Count, count)
+	}
+	if getStackCount(with)+action.Count > maxSize {
+		return fmt.Errorf("a stack holds at most %d", maxSize)
+	}
+	setStackCount(item, count-action.Count)
+	setStackCount(with, getStackCount(with)+action.Count)
+	return nil
+}
+
+func itemEventFold(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemValueAction](data)
+	if err != nil {
+		return err
+	}
+	item, ok := ctx.inventory.Item(action.Item)
+	if !ok {
+		return fmt.Errorf("unknown item %s", action.Item)
+	}
+	template, err := ctx.inventory.Template(item.ID)
+	if err != nil {
+		return err
+	}
+	if !template.Props.Foldable {
+		return fmt.Errorf("item %s cannot be folded", item.ID)
+	}
+
+	if item.Upd == nil {
+		item.Upd = &structs.ItemUpd{}
+	}
+	item.Upd.Foldable = &structs.UpdFoldable{Folded: action.Value}
+
+	// unfolding makes the item larger, so it must still fit where it is
+	if item.Location != nil && item.Location.Index == nil {
+		return checkPlacement(ctx.inventory, item.ID)
+	}
+	return nil
+}
+
+func itemEventToggle(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemValueAction](data)
+	if err != nil {
+		return err
+	}
+	item, ok := ctx.inventory.Item(action.Item)
+	if !ok {
+		return fmt.Errorf("unknown item %s", action.Item)
+	}
+
+	if item.Upd == nil {
+		item.Upd = &structs.ItemUpd{}
+	}
+	item.Upd.Togglable = &structs.UpdTogglable{On: action.Value}
+	return nil
+}
+
+func itemEventTag(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemTagAction](data)
+	if err != nil {
+		return err
+	}
+	item, ok := ctx.inventory.Item(action.Item)
+	if !ok {
+		return fmt.Errorf("unknown item %s", action.Item)
+	}
+
+	if item.Upd == nil {
+		item.Upd = &structs.ItemUpd{}
+	}
+	item.Upd.Tag = &structs.UpdTag{Name: action.TagName, Color: action.TagColor}
+	return nil
+}
+
+func itemEventBind(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemBindAction](data)
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.inventory.Item(action.Item); !ok {
+		return fmt.Errorf("unknown item %s", action.Item)
+	}
+
+	fastPanel := ctx.character.Inventory.FastPanel
+	if fastPanel == nil {
+		fastPanel = make(map[string]interface{})
+		ctx.character.Inventory.FastPanel = fastPanel
+	}
+	for index, id := range fastPanel {
+		if id == action.Item {
+			delete(fastPanel, index)
+		}
+	}
+	fastPanel[action.Index] = action.Item
+	return nil
+}
+
+func itemEventExamine(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemExamineAction](data)
+	if err != nil {
+		return err
+	}
+
+	tpl := ""
+	if item, ok := ctx.inventory.Item(action.Item); ok {
+		tpl = item.Tpl
+	} else if action.FromOwner != nil {
+		if trader, ok := Database.traders[action.FromOwner.ID]; ok {
+			for _, item := range trader.baseAssort.Items {
+				if item.ID == action.Item {
+					tpl = item.Tpl
+					break
+				}
+			}
+		} else if _, ok := Database.items[action.Item]; ok {
+			// hideout and flea market items are examined by template
+			tpl = action.Item
+		}
+	}
+	template, ok := Database.items[tpl]
+	if !ok {
+		return fmt.Errorf("unknown item %s", action.Item)
+	}
+
+	if ctx.character.Encyclopedia == nil {
+		ctx.character.Encyclopedia = make(map[string]bool)
+	}
+	if _, ok := ctx.character.Encyclopedia[tpl]; !ok {
+		ctx.character.Encyclopedia[tpl] = false
+		ctx.character.Info.Experience += template.Props.ExamineExperience
+	}
+	return nil
+}
+
+func itemEventReadEncyclopedia(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemReadEncyclopediaAction](data)
+	if err != nil {
+		return err
+	}
+
+	if ctx.character.Encyclopedia == nil {
+		ctx.character.Encyclopedia = make(map[string]bool)
+	}
+	for _, tpl := range action.IDs {
+		ctx.character.Encyclopedia[tpl] = true
+	}
+	return nil
+}
+
+func itemEventRemove(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemRemoveAction](data)
+	if err != nil {
+		return err
+	}
+	if err := checkMovable(ctx, action.Item); err != nil {
+		return err
+	}
+
+	removed := ctx.inventory.Remove(action.Item)
+	unbindItems(ctx.character, removed)
+	return nil
+}
+
+// unbindItems clears the fast panel slots of removed items
+func unbindItems(character *structs.Character, removed []*structs.InventoryItem) {
+	ids := make(map[string]bool, len(removed))
+	for _, item := range removed {
+		ids[item.ID] = true
+	}
+	for index, id := range character.Inventory.FastPanel {
+		if id, ok := id.(string); ok && ids[id] {
+			delete(character.Inventory.FastPanel, index)
+		}
+	}
+}
+
+func itemEventSwap(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[ItemSwapAction](data)
+	if err != nil {
+		return err
+	}
+
+	if err := checkMovable(ctx, action.Item); err != nil {
+		return err
+	}
+	if err := checkMovable(ctx, action.Item2); err != nil {
+		return err
+	}
+
+	// both items move before either is checked, as each takes the place of the other
+	if err := ctx.inventory.Move(action.Item, action.To.ID, action.To.Container, action.To.Location); err != nil {
+		return err
+	}
+	if err := ctx.inventory.Move(action.Item2, action.To2.ID, action.To2.Container, action.To2.Location); err != nil {
+		return err
+	}
+	if err := checkPlacement(ctx.inventory, action.Item); err != nil {
+		return err
+	}
+	return checkPlacement(ctx.inventory, action.Item2)
+}
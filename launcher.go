@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LauncherLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Edition  string `json:"edition"`
+}
+
+type LauncherChangePasswordRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Change   string `json:"change"`
+}
+
+// getEditionNames returns the names of the editions an account can register with
+func getEditionNames() []string {
+	editions := make([]string, 0, len(Database.editions))
+	for name := range Database.editions {
+		editions = append(editions, name)
+	}
+	sort.Strings(editions)
+	return editions
+}
+
+// authenticate returns the profile of username, locked, if password matches,
+// sending an error otherwise. The caller unlocks the profile when done.
+func authenticate(c *gin.Context, username string, password string) (*ProfileStruct, bool) {
+	if profile, ok := getProfileByUsername(username); ok {
+		if profile, ok := lockProfile(profile.account.ID); ok {
+			if checkPassword(profile.account, password) {
+				return profile, true
+			}
+			profile.lock.Unlock()
+		}
+	}
+
+	sendError(c, BACKEND_NOT_AUTHORIZED, "wrong username or password")
+	return nil, false
+}
+
+func launcherServerConnect(c *gin.Context) {
+	data := map[string]interface{}{
+		"backendUrl": getBackendURL(),
+		"name":       Database.core.serverConfig["name"],
+		"editions":   getEditionNames(),
+	}
+	sendResponse(c, data)
+}
+
+func launcherProfileRegister(c *gin.Context) {
+	body := getBody[LauncherLoginRequest](c)
+	if body.Username == "" || body.Password == "" {
+		sendError(c, BACKEND_BAD_REQUEST, "missing username or password")
+		return
+	}
+	if _, ok := Database.editions[body.Edition]; !ok {
+		sendError(c, BACKEND_BAD_REQUEST, "unknown edition "+body.Edition)
+		return
+	}
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+	if _, ok := getProfileByUsername(body.Username); ok {
+		sendError(c, BACKEND_BAD_REQUEST, "username "+body.Username+" is taken")
+		return
+	}
+
+	profile, err := createProfile(body.Username, body.Password, body.Edition)
+	if err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not create the account")
+		return
+	}
+	sendResponse(c, profile.account.ID)
+}
+
+func launcherProfileLogin(c *gin.Context) {
+	body := getBody[LauncherLoginRequest](c)
+	profile, ok := authenticate(c, body.Username, body.Password)
+	if !ok {
+		return
+	}
+	profile.lock.Unlock()
+
+	sendResponse(c, profile.account.ID)
+}
+
+func launcherProfileRemove(c *gin.Context) {
+	body := getBody[LauncherLoginRequest](c)
+	profile, ok := authenticate(c, body.Username, body.Password)
+	if !ok {
+		return
+	}
+	defer profile.lock.Unlock()
+
+	if err := removeProfile(profile.account.ID); err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not remove the account")
+		return
+	}
+	sendResponse(c, true)
+}
+
+func launcherProfileChangePassword(c *gin.Context) {
+	body := getBody[LauncherChangePasswordRequest](c)
+	if body.Change == "" {
+		sendError(c, BACKEND_BAD_REQUEST, "missing new password")
+		return
+	}
+	profile, ok := authenticate(c, body.Username, body.Password)
+	if !ok {
+		return
+	}
+	defer profile.lock.Unlock()
+
+	hash, err := hashPassword(body.Change)
+	if err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not change the password")
+		return
+	}
+
+	profile.account.Password = hash
+	markProfileDirty(profile, PROFILE_ACCOUNT)
+	if err := saveProfile(profile.account.ID); err != nil {
+		log.Println(err)
+		sendError(c, BACKEND_UNKNOWN_ERROR, "could not change the password")
+		return
+	}
+	sendResponse(c, true)
+}
@@ -1,17 +1,73 @@
 package main
 
 import (
+	"MT-GO/plugins"
 	"log"
+	"os"
 )
 
 func main() {
 
-	dbErr := initializeDatabase()
+	if len(os.Args) > 1 {
+		commandErr := runCommand(os.Args[1], os.Args[2:])
+		if closeErr := closeProfileStore(); closeErr != nil {
+			log.Printf("error closing profile store: %v", closeErr)
+		}
+		if commandErr != nil {
+			log.Fatalf("error running %s: %v", os.Args[1], commandErr)
+		}
+		return
+	}
+
+	overlay, modsErr := loadModOverlay()
+	if modsErr != nil {
+		log.Fatalf("error loading mods: %v", modsErr)
+	}
+
+	dbErr := initializeDatabase(overlay)
 	if dbErr != nil {
 		log.Fatalf("error initializing database: %v", dbErr)
 	}
 
+	stockErr := setTraderStock()
+	if stockErr != nil {
+		log.Fatalf("error restoring trader stock: %v", stockErr)
+	}
+
+	pluginsErr := plugins.Initialize(&pluginDatabase{&Database})
+	if pluginsErr != nil {
+		log.Fatalf("error initializing mods: %v", pluginsErr)
+	}
+
+	if isHotReloadEnabled() {
+		go watchDatabase()
+	}
+
+	stopAutosave := make(chan struct{})
+	go autosaveProfiles(getAutosaveInterval(), stopAutosave)
+
+	stopRestocks := make(chan struct{})
+	go scheduleRestocks(stopRestocks)
+
 	ginErr := setGin()
+	close(stopAutosave)
+	close(stopRestocks)
+
+	saveErr := saveDirtyProfiles()
+	if saveErr != nil {
+		log.Printf("error saving profiles on shutdown: %v", saveErr)
+	}
+
+	stockErr = saveTraderStock()
+	if stockErr != nil {
+		log.Printf("error saving trader stock on shutdown: %v", stockErr)
+	}
+
+	closeErr := closeProfileStore()
+	if closeErr != nil {
+		log.Printf("error closing profile store: %v", closeErr)
+	}
+
 	if ginErr != nil {
 		log.Fatalf("error setting gin: %v", ginErr)
 	}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"MT-GO/tools"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// profileDocuments are the files of a profile folder as generic JSON, keyed by
+// file name. Files the profile does not have are absent.
+type profileDocuments map[string]map[string]interface{}
+
+// UnmarshalJSON keeps numbers as written, like readProfileDocuments
+func (d *profileDocuments) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	documents := make(map[string]map[string]interface{})
+	if err := decoder.Decode(&documents); err != nil {
+		return err
+	}
+	*d = documents
+	return nil
+}
+
+// profileMigration upgrades a profile saved with schema version-1 to version
+type profileMigration struct {
+	version     int
+	description string
+	migrate     func(profile profileDocuments) error
+}
+
+// profileMigrations run in order on every profile whose account.json has an
+// older schemaVersion. Append new migrations with the next version; never
+// change or remove one that has shipped.
+var profileMigrations = []profileMigration{
+	{1, "hash plaintext passwords", migrateHashPasswords},
+	{2, "fill in missing LowerNickname", migrateLowerNickname},
+}
+
+func migrateHashPasswords(profile profileDocuments) error {
+	account := profile[ACCOUNT_FILE]
+	password, _ := account["password"].(string)
+	if password == "" || isPasswordHashed(password) {
+		return nil
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	account["password"] = hash
+	return nil
+}
+
+func migrateLowerNickname(profile profileDocuments) error {
+	info, ok := profile[CHARACTER_FILE]["Info"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if lower, _ := info["LowerNickname"].(string); lower != "" {
+		return nil
+	}
+	if nickname, _ := info["Nickname"].(string); nickname != "" {
+		info["LowerNickname"] = strings.ToLower(nickname)
+	}
+	return nil
+}
+
+// getProfileSchemaVersion returns the schema version profiles are saved with
+func getProfileSchemaVersion() int {
+	if len(profileMigrations) == 0 {
+		return 0
+	}
+	return profileMigrations[len(profileMigrations)-1].version
+}
+
+// migrationResult describes the migrations run on one profile
+type migrationResult struct {
+	profileID string
+	from      int
+	to        int
+	applied   []string
+	changes   map[string][]string
+	backup    string
+}
+
+func (r *migrationResult) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Profile %s: schema version %d to %d\n", r.profileID, r.from, r.to)
+	for _, description := range r.applied {
+		fmt.Fprintf(&builder, "  applied: %s\n", description)
+	}
+
+	files := make([]string, 0, len(r.changes))
+	for file := range r.changes {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		for _, path := range r.changes[file] {
+			fmt.Fprintf(&builder, "  changed: %s %s\n", file, path)
+		}
+	}
+	if r.backup != "" {
+		fmt.Fprintf(&builder, "  backup: %s\n", r.backup)
+	}
+	return builder.String()
+}
+
+// readProfileDocuments reads the files of a profile from the store and
+// returns them both as saved and decoded. Numbers are kept as written so files
+// that are not migrated round-trip unchanged.
+func readProfileDocuments(store ProfileStore, profileID string) (map[string][]byte, profileDocuments, error) {
+	files, err := readProfileFiles(func(file string) ([]byte, error) {
+		return store.Read(profileID, file)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	documents := make(profileDocuments, len(files))
+	for file, data := range files {
+		document, err := decodeProfileDocument(data)
+		if err != nil {
+			return nil, nil, &tools.DecodeError{File: profileID + "/" + file, Err: err}
+		}
+		documents[file] = document
+	}
+	return files, documents, nil
+}
+
+// copyDocument returns a deep copy of a decoded JSON value
+func copyDocument(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, entry := range value {
+			copied[key] = copyDocument(entry)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, entry := range value {
+			copied[i] = copyDocument(entry)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+func decodeProfileDocument(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	document := make(map[string]interface{})
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// getDocumentSchemaVersion returns the schemaVersion of account.json, 0 for
+// profiles saved before versioning
+func getDocumentSchemaVersion(documents profileDocuments) int {
+	number, ok := documents[ACCOUNT_FILE]["schemaVersion"].(json.Number)
+	if !ok {
+		return 0
+	}
+	version, _ := number.Int64()
+	return int(version)
+}
+
+// applyProfileMigrations runs the migrations newer than from on documents and
+// sets their schema version, returning the descriptions of those run
+func applyProfileMigrations(documents profileDocuments, from int) ([]string, error) {
+	var applied []string
+	for _, migration := range profileMigrations {
+		if migration.version <= from {
+			continue
+		}
+		if err := migration.migrate(documents); err != nil {
+			return nil, fmt.Errorf("error migrating to version %d (%s): %w", migration.version, migration.description, err)
+		}
+		applied = append(applied, migration.description)
+	}
+	documents[ACCOUNT_FILE]["schemaVersion"] = json.Number(fmt.Sprint(getProfileSchemaVersion()))
+	return applied, nil
+}
+
+// migrateProfile runs the migrations a profile is missing. Unless dryRun is
+// set, the profile is backed up first and the changed files are rewritten.
+// It returns nil if the profile is up to date.
+func migrateProfile(profileID string, dryRun bool) (*migrationResult, error) {
+	store, err := getProfileStore()
+	if err != nil {
+		return nil, err
+	}
+	files, documents, err := readProfileDocuments(store, profileID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := documents[ACCOUNT_FILE]; !ok {
+		return nil, fmt.Errorf("profile %s has no %s", profileID, ACCOUNT_FILE)
+	}
+
+	from := getDocumentSchemaVersion(documents)
+	to := getProfileSchemaVersion()
+	if from >= to {
+		return nil, nil
+	}
+
+	// the migrations change documents in place, so the diff and the backup
+	// use a copy and the files as they were read
+	original := make(profileDocuments, len(documents))
+	for file, document := range documents {
+		original[file] = copyDocument(document).(map[string]interface{})
+	}
+
+	applied, err := applyProfileMigrations(documents, from)
+	if err != nil {
+		return nil, fmt.Errorf("error migrating profile %s: %w", profileID, err)
+	}
+	result := &migrationResult{profileID: profileID, from: from, to: to, applied: applied, changes: make(map[string][]string)}
+
+	for file, document := range documents {
+		var changes []string
+		diffDocuments("", original[file], document, &changes)
+		if len(changes) != 0 {
+			sort.Strings(changes)
+			result.changes[file] = changes
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	backup, err := writeProfileBackup(store, profileID, files)
+	if err != nil {
+		return nil, err
+	}
+	result.backup = backup
+
+	changed := make(map[string]interface{}, len(result.changes))
+	for file := range result.changes {
+		changed[file] = documents[file]
+	}
+	if err := writeProfileFiles(profileID, changed); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// diffDocuments appends the JSON pointer of every value that differs between
+// two documents, descending into objects only
+func diffDocuments(path string, before interface{}, after interface{}, changes *[]string) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if !beforeIsMap || !afterIsMap {
+		if !reflect.DeepEqual(before, after) {
+			if path == "" {
+				path = "/"
+			}
+			*changes = append(*changes, path)
+		}
+		return
+	}
+
+	for key, value := range afterMap {
+		diffDocuments(path+"/"+key, beforeMap[key], value, changes)
+	}
+	for key, value := range beforeMap {
+		if _, ok := afterMap[key]; !ok {
+			diffDocuments(path+"/"+key, value, nil, changes)
+		}
+	}
+}
+
+// migrateCommand migrates every profile on disk, or with -dry-run reports
+// what migrating them would change without writing anything
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+	profileIDs, err := store.List()
+	if err != nil {
+		return fmt.Errorf("error reading profiles: %w", err)
+	}
+	sort.Strings(profileIDs)
+
+	migrated := 0
+	for _, profileID := range profileIDs {
+		result, err := migrateProfile(profileID, *dryRun)
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			fmt.Print(result.String())
+			migrated++
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d of %d profiles would be migrated to schema version %d\n", migrated, len(profileIDs), getProfileSchemaVersion())
+	} else {
+		fmt.Printf("Migrated %d of %d profiles to schema version %d\n", migrated, len(profileIDs), getProfileSchemaVersion())
+	}
+	return nil
+}
+
+// logMigration reports a migration run while loading profiles
+func logMigration(result *migrationResult) {
+	for _, line := range strings.Split(strings.TrimRight(result.String(), "\n"), "\n") {
+		log.Println(line)
+	}
+}
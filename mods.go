@@ -0,0 +1,279 @@
+package main
+
+import (
+	"MT-GO/tools"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const MODS_FILE_PATH string = "mods"
+const MOD_MANIFEST_FILE string = "mod.json"
+
+// ModManifest is the mod.json every mod folder declares
+type ModManifest struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Author    string `json:"author"`
+	LoadOrder int    `json:"loadOrder"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+
+	path string
+}
+
+// IsEnabled returns false only when the manifest explicitly disables the mod
+func (m *ModManifest) IsEnabled() bool {
+	return m.Enabled == nil || *m.Enabled
+}
+
+const (
+	MOD_MERGE_FILE = "merge"
+	MOD_PATCH_FILE = "patch"
+)
+
+// modFile is one file a mod applies on top of a database file. A file named
+// like its target is a JSON Merge Patch; one ending in .patch.json is a list
+// of JSON Patch operations. Mods can only change files the database already
+// has: new entries, such as quests or assort items, are merged into an
+// existing file, and a new file, such as another trader folder, is an error.
+type modFile struct {
+	mod  *ModManifest
+	kind string
+	path string
+}
+
+// modOverlay maps database file paths to the mod files patching them, in load order
+type modOverlay map[string][]modFile
+
+// loadModOverlay reads every mod manifest, orders enabled mods by loadOrder
+// then name, and indexes the database files they patch. The overlay is only
+// applied by the database loaders it is passed to.
+func loadModOverlay() (modOverlay, error) {
+	overlay := make(modOverlay)
+	if !tools.FileExist(MODS_FILE_PATH) {
+		return overlay, nil
+	}
+
+	modsDirectory, err := tools.GetDirectoriesFrom(MODS_FILE_PATH)
+	if err != nil {
+		return nil, fmt.Errorf("error reading mods directory: %w", err)
+	}
+
+	var mods []*ModManifest
+	for _, directory := range modsDirectory {
+		modPath := filepath.Join(MODS_FILE_PATH, directory)
+		manifestPath := filepath.Join(modPath, MOD_MANIFEST_FILE)
+		if !tools.FileExist(manifestPath) {
+			log.Printf("Skipping mod %s: no %s", directory, MOD_MANIFEST_FILE)
+			continue
+		}
+
+		manifest := &ModManifest{}
+		if err := tools.ReadParsedInto(manifestPath, manifest); err != nil {
+			return nil, fmt.Errorf("error reading manifest for mod %s: %w", directory, err)
+		}
+		if manifest.Name == "" {
+			manifest.Name = directory
+		}
+		manifest.path = modPath
+
+		if !manifest.IsEnabled() {
+			log.Printf("Mod %s is disabled", manifest.Name)
+			continue
+		}
+		mods = append(mods, manifest)
+	}
+
+	sort.SliceStable(mods, func(i, j int) bool {
+		if mods[i].LoadOrder != mods[j].LoadOrder {
+			return mods[i].LoadOrder < mods[j].LoadOrder
+		}
+		return mods[i].Name < mods[j].Name
+	})
+
+	for _, mod := range mods {
+		if err := overlay.setModFiles(mod); err != nil {
+			return nil, fmt.Errorf("error reading files for mod %s: %w", mod.Name, err)
+		}
+		log.Printf("Loaded mod %s %s by %s", mod.Name, mod.Version, mod.Author)
+	}
+
+	overlay.reportConflicts()
+	return overlay, nil
+}
+
+// setModFiles indexes the files under the mod's database folder by the
+// database file they target, which must exist
+func (overlay modOverlay) setModFiles(mod *ModManifest) error {
+	databasePath := filepath.Join(mod.path, "database")
+	if !tools.FileExist(databasePath) {
+		return nil
+	}
+
+	return filepath.WalkDir(databasePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+
+		relative, err := filepath.Rel(mod.path, path)
+		if err != nil {
+			return err
+		}
+
+		file := modFile{mod: mod, kind: MOD_MERGE_FILE, path: path}
+		if strings.HasSuffix(relative, ".patch.json") {
+			file.kind = MOD_PATCH_FILE
+			relative = strings.TrimSuffix(relative, ".patch.json") + ".json"
+		}
+
+		target := normalizeModPath(relative)
+		if !tools.FileExist(target) {
+			return fmt.Errorf("%s targets %s, which does not exist: mods can only patch existing database files", path, target)
+		}
+		overlay[target] = append(overlay[target], file)
+		return nil
+	})
+}
+
+func normalizeModPath(path string) string {
+	if filepath.IsAbs(path) {
+		if cwd, err := os.Getwd(); err == nil {
+			if relative, err := filepath.Rel(cwd, path); err == nil {
+				path = relative
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// patches reports whether the overlay patches any file under the loader's paths
+func (overlay modOverlay) patches(loader databaseLoader) bool {
+	for _, path := range loader.paths {
+		path = normalizeModPath(path)
+		for target := range overlay {
+			if target == path || strings.HasPrefix(target, path+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// read is the tools.FileReader the database loaders are given: it reads a
+// file and applies the mod files targeting it in load order
+func (overlay modOverlay) read(filePath string) ([]byte, error) {
+	data, err := tools.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	files, ok := overlay[normalizeModPath(filePath)]
+	if !ok {
+		return data, nil
+	}
+
+	document, err := decodeModJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", filePath, err)
+	}
+
+	for _, file := range files {
+		document, err = applyModFile(document, file)
+		if err != nil {
+			return nil, fmt.Errorf("mod %s: error applying %s to %s: %w", file.mod.Name, file.path, filePath, err)
+		}
+	}
+
+	return json.Marshal(document)
+}
+
+func applyModFile(document interface{}, file modFile) (interface{}, error) {
+	raw, err := os.ReadFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.kind == MOD_PATCH_FILE {
+		var operations []tools.PatchOperation
+		if err := tools.UnmarshalInto(file.path, "", raw, &operations); err != nil {
+			return nil, err
+		}
+		return tools.ApplyPatch(document, operations)
+	}
+
+	patch, err := decodeModJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	return tools.MergePatch(document, patch), nil
+}
+
+// decodeModJSON keeps numbers as json.Number so patched files keep their exact values
+func decodeModJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// reportConflicts logs every JSON path of a database file changed by more
+// than one mod; the mod later in load order wins
+func (overlay modOverlay) reportConflicts() {
+	for target, files := range overlay {
+		owners := make(map[string]*ModManifest)
+		for _, file := range files {
+			for _, path := range getModFilePaths(file) {
+				for owned, owner := range owners {
+					if owner == file.mod || !pathsOverlap(owned, path) {
+						continue
+					}
+					log.Printf("Mod conflict in %s at %s: %s overrides %s", target, path, file.mod.Name, owner.Name)
+				}
+				owners[path] = file.mod
+			}
+		}
+	}
+}
+
+func getModFilePaths(file modFile) []string {
+	raw, err := os.ReadFile(file.path)
+	if err != nil {
+		return nil
+	}
+
+	if file.kind == MOD_PATCH_FILE {
+		var operations []tools.PatchOperation
+		if err := json.Unmarshal(raw, &operations); err != nil {
+			return nil
+		}
+		paths := make([]string, 0, len(operations))
+		for _, operation := range operations {
+			// appending to an array never overwrites another mod's change
+			if !strings.HasSuffix(operation.Path, "/-") {
+				paths = append(paths, operation.Path)
+			}
+		}
+		return paths
+	}
+
+	patch, err := decodeModJSON(raw)
+	if err != nil {
+		return nil
+	}
+	return tools.MergePatchPaths(patch)
+}
+
+// pathsOverlap is true when one JSON pointer equals or contains the other
+func pathsOverlap(a string, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
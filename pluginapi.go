@@ -0,0 +1,42 @@
+package main
+
+// Compiled-in mods register themselves with plugins.Register from an init
+// function; add a blank import of the mod's package here to build it in.
+import (
+	"MT-GO/structs"
+)
+
+// pluginDatabase exposes the loaded database to compiled-in mods
+type pluginDatabase struct {
+	db *DatabaseStruct
+}
+
+func (p *pluginDatabase) Item(id string) (*structs.DatabaseItem, bool) {
+	item, ok := p.db.items[id]
+	return item, ok
+}
+
+func (p *pluginDatabase) Quest(id string) (*structs.Quest, bool) {
+	quest, ok := p.db.quests[id]
+	return quest, ok
+}
+
+func (p *pluginDatabase) TraderBase(id string) (*structs.TraderBase, bool) {
+	trader, ok := p.db.traders[id]
+	if !ok {
+		return nil, false
+	}
+	return trader.base, true
+}
+
+func (p *pluginDatabase) TraderAssort(id string) (*structs.Assort, bool) {
+	trader, ok := p.db.traders[id]
+	if !ok {
+		return nil, false
+	}
+	return trader.baseAssort, true
+}
+
+func (p *pluginDatabase) Globals() map[string]interface{} {
+	return p.db.core.globals
+}
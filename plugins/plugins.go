@@ -0,0 +1,181 @@
+// Package plugins is the API for compiled-in server mods. A mod registers
+// itself from an init function and is imported for its side effect in main:
+//
+//	func init() {
+//		plugins.Register(&MyMod{})
+//	}
+package plugins
+
+import (
+	"MT-GO/structs"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModInfo describes a mod and the mods it has to be initialized after
+type ModInfo struct {
+	Name         string
+	Version      string
+	Author       string
+	Dependencies []string
+}
+
+// Mod is a compiled-in server mod. Embed BaseMod to only implement the hooks
+// a mod needs. Returning an error from OnDatabaseLoaded or OnRoutesRegistered
+// stops the server from starting; errors from the other hooks are logged.
+type Mod interface {
+	Info() ModInfo
+	OnDatabaseLoaded(db Database) error
+	OnRoutesRegistered(router *gin.Engine) error
+	OnProfileSaved(profileID string, character *structs.Character) error
+	OnTrade(profileID string, trade TradeResult) error
+}
+
+// BaseMod implements every hook of Mod as a no-op
+type BaseMod struct{}
+
+func (BaseMod) OnDatabaseLoaded(Database) error                 { return nil }
+func (BaseMod) OnRoutesRegistered(*gin.Engine) error            { return nil }
+func (BaseMod) OnProfileSaved(string, *structs.Character) error { return nil }
+func (BaseMod) OnTrade(string, TradeResult) error               { return nil }
+
+// Database gives mods access to the loaded database. Values are live, so
+// changes made in OnDatabaseLoaded are seen by every request; item templates
+// and location bases are served from their original JSON and are read-only.
+type Database interface {
+	Item(id string) (*structs.DatabaseItem, bool)
+	Quest(id string) (*structs.Quest, bool)
+	TraderBase(id string) (*structs.TraderBase, bool)
+	TraderAssort(id string) (*structs.Assort, bool)
+	Globals() map[string]interface{}
+}
+
+// TradeResult is passed to OnTrade
+type TradeResult struct {
+	TraderID string
+	Type     string
+	ItemID   string
+	Count    int
+}
+
+var registered = make(map[string]Mod)
+
+// loaded holds the registered mods in dependency order once Initialize ran
+var loaded []Mod
+
+// Register adds a mod to the registry. It panics on a duplicate name, as
+// that can only happen from init functions at build time.
+func Register(mod Mod) {
+	name := mod.Info().Name
+	if _, ok := registered[name]; ok {
+		panic(fmt.Sprintf("plugins: mod %s registered twice", name))
+	}
+	registered[name] = mod
+}
+
+// Initialize orders the registered mods so each one comes after its
+// dependencies and calls OnDatabaseLoaded on each of them.
+func Initialize(db Database) error {
+	ordered, err := sortByDependencies()
+	if err != nil {
+		return err
+	}
+	loaded = ordered
+
+	for _, mod := range loaded {
+		info := mod.Info()
+		if err := mod.OnDatabaseLoaded(db); err != nil {
+			return fmt.Errorf("mod %s: error on database loaded: %w", info.Name, err)
+		}
+		log.Printf("Initialized mod %s %s by %s", info.Name, info.Version, info.Author)
+	}
+	return nil
+}
+
+func sortByDependencies() ([]Mod, error) {
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(names))
+	ordered := make([]Mod, 0, len(names))
+
+	var visit func(name string, parent string) error
+	visit = func(name string, parent string) error {
+		mod, ok := registered[name]
+		if !ok {
+			return fmt.Errorf("mod %s depends on %s, which is not registered", parent, name)
+		}
+
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("mod %s has a circular dependency on %s", parent, name)
+		}
+
+		state[name] = visiting
+		for _, dependency := range mod.Info().Dependencies {
+			if err := visit(dependency, name); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, mod)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// DatabaseLoaded calls OnDatabaseLoaded again after the database was reloaded
+func DatabaseLoaded(db Database) {
+	for _, mod := range loaded {
+		if err := mod.OnDatabaseLoaded(db); err != nil {
+			log.Printf("Mod %s: error on database loaded: %v", mod.Info().Name, err)
+		}
+	}
+}
+
+// RoutesRegistered lets every mod add its own routes
+func RoutesRegistered(router *gin.Engine) error {
+	for _, mod := range loaded {
+		if err := mod.OnRoutesRegistered(router); err != nil {
+			return fmt.Errorf("mod %s: error on routes registered: %w", mod.Info().Name, err)
+		}
+	}
+	return nil
+}
+
+// ProfileSaved notifies every mod that a profile was written to disk
+func ProfileSaved(profileID string, character *structs.Character) {
+	for _, mod := range loaded {
+		if err := mod.OnProfileSaved(profileID, character); err != nil {
+			log.Printf("Mod %s: error on profile saved: %v", mod.Info().Name, err)
+		}
+	}
+}
+
+// Traded notifies every mod that a profile traded with a trader
+func Traded(profileID string, trade TradeResult) {
+	for _, mod := range loaded {
+		if err := mod.OnTrade(profileID, trade); err != nil {
+			log.Printf("Mod %s: error on trade: %v", mod.Info().Name, err)
+		}
+	}
+}
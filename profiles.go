@@ -0,0 +1,356 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Files every profile folder holds
+const (
+	ACCOUNT_FILE   string = "account.json"
+	CHARACTER_FILE string = "character.json"
+	STORAGE_FILE   string = "storage.json"
+	DIALOGUES_FILE string = "dialogues.json"
+	RAID_FILE      string = "raid.json"
+	PURCHASES_FILE string = "purchases.json"
+)
+
+// profilesLock guards adding and removing entries of Database.profiles
+var profilesLock sync.RWMutex
+
+// profileNamesLock serializes checking and claiming usernames, nicknames and
+// the ids of new profiles, so two requests cannot both take the same one. It may be taken while
+// holding a profile lock, but no profile may be locked while holding it.
+var profileNamesLock sync.Mutex
+
+// profileNicknames maps the lowercase nickname of every character to the id
+// of its profile, so nicknames are checked without locking other profiles.
+// Guarded by profileNamesLock.
+var profileNicknames = make(map[string]string)
+
+// getProfiles returns every profile in memory
+func getProfiles() map[string]*ProfileStruct {
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+
+	profiles := make(map[string]*ProfileStruct, len(Database.profiles))
+	for profileID, profile := range Database.profiles {
+		profiles[profileID] = profile
+	}
+	return profiles
+}
+
+// lockProfile returns a profile with its lock held; the caller unlocks it when
+// done. Requests of one session run one after another while other sessions
+// run in parallel. A profile replaced while waiting, by a restore or reset, is
+// looked up again so the caller always holds the current one.
+func lockProfile(profileID string) (*ProfileStruct, bool) {
+	for {
+		profile, ok := getProfile(profileID)
+		if !ok {
+			return nil, false
+		}
+
+		profile.lock.Lock()
+		if current, ok := getProfile(profileID); ok && current == profile {
+			return profile, true
+		}
+		profile.lock.Unlock()
+	}
+}
+
+// writeProfileFiles serializes files of a profile and writes them to the store
+func writeProfileFiles(profileID string, files map[string]interface{}) error {
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string][]byte, len(files))
+	for file, content := range files {
+		data[file] = []byte(tools.Stringify(content, false))
+	}
+	if err := store.Write(profileID, data); err != nil {
+		return fmt.Errorf("error writing profile %s: %w", profileID, err)
+	}
+	return nil
+}
+
+// getProfileByUsername returns the profile whose account has username, ignoring
+// case. Usernames never change, so the profile is not locked.
+func getProfileByUsername(username string) (*ProfileStruct, bool) {
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+
+	for _, profile := range Database.profiles {
+		if profile.account != nil && strings.EqualFold(profile.account.Username, username) {
+			return profile, true
+		}
+	}
+	return nil, false
+}
+
+// hashPassword returns the bcrypt hash stored in account.json
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// isPasswordHashed reports whether an account password is a bcrypt hash
+// rather than the plaintext older accounts were saved with
+func isPasswordHashed(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+// checkPassword reports whether password matches the one of account
+func checkPassword(account *structs.Account, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)) == nil
+}
+
+// createProfile creates the folder and files of a new account, without a
+// character until the client creates one. Callers must hold profileNamesLock
+// from checking the username until it returns.
+func createProfile(username string, password string, edition string) (*ProfileStruct, error) {
+	profileID := tools.GenerateMongoId()
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &ProfileStruct{
+		account: &structs.Account{
+			ID:                  profileID,
+			Username:            username,
+			Password:            hash,
+			Wipe:                true,
+			Edition:             edition,
+			Lang:                "en",
+			Friends:             []string{},
+			FriendRequestInbox:  []interface{}{},
+			FriendRequestOutbox: []interface{}{},
+			SchemaVersion:       getProfileSchemaVersion(),
+		},
+		storage: &structs.Storage{
+			ID:     profileID,
+			Suites: []string{},
+		},
+		dialogues: make(structs.Dialogues),
+	}
+
+	files := map[string]interface{}{
+		ACCOUNT_FILE:   profile.account,
+		CHARACTER_FILE: map[string]interface{}{},
+		STORAGE_FILE:   profile.storage,
+		DIALOGUES_FILE: profile.dialogues,
+	}
+	if err := writeProfileFiles(profileID, files); err != nil {
+		return nil, err
+	}
+
+	profilesLock.Lock()
+	Database.profiles[profileID] = profile
+	profilesLock.Unlock()
+
+	return profile, nil
+}
+
+// removeProfile deletes a profile and its backups. Callers must hold the
+// profile lock, so requests waiting for it find the profile gone.
+func removeProfile(profileID string) error {
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+
+	profilesLock.Lock()
+	delete(Database.profiles, profileID)
+	profilesLock.Unlock()
+
+	profileNamesLock.Lock()
+	setProfileNickname(profileID, "")
+	profileNamesLock.Unlock()
+
+	if err := store.Remove(profileID); err != nil {
+		return fmt.Errorf("error removing profile %s: %w", profileID, err)
+	}
+	return nil
+}
+
+// Sides a character can be created with
+const (
+	SIDE_BEAR string = "Bear"
+	SIDE_USEC string = "Usec"
+)
+
+// isNicknameTaken reports whether the character of another profile than
+// profileID has nickname, ignoring case. Callers must hold profileNamesLock.
+func isNicknameTaken(nickname string, profileID string) bool {
+	owner, ok := profileNicknames[strings.ToLower(nickname)]
+	return ok && owner != profileID
+}
+
+// setProfileNickname records the nickname of the character of a profile in
+// profileNicknames, releasing the one it had before. An empty nickname only
+// releases it. Callers must hold profileNamesLock.
+func setProfileNickname(profileID string, nickname string) {
+	for name, owner := range profileNicknames {
+		if owner == profileID {
+			delete(profileNicknames, name)
+		}
+	}
+	if nickname != "" {
+		profileNicknames[strings.ToLower(nickname)] = profileID
+	}
+}
+
+// getCharacterNickname returns the nickname of a profile's character, or an
+// empty string if it has none yet
+func getCharacterNickname(profile *ProfileStruct) string {
+	if profile.character == nil {
+		return ""
+	}
+	return profile.character.Info.Nickname
+}
+
+// getCustomizationProps returns the _name and _props of a customization
+// available to side, if it exists
+func getCustomizationProps(id string, side string) (string, map[string]interface{}, bool) {
+	customization, ok := Database.customization[id].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	name, _ := customization["_name"].(string)
+	props, _ := customization["_props"].(map[string]interface{})
+
+	sides, _ := props["Side"].([]interface{})
+	for _, s := range sides {
+		if s == side {
+			return name, props, true
+		}
+	}
+	return "", nil, false
+}
+
+// cloneCharacter returns a deep copy of a character template
+func cloneCharacter(template *structs.Character) (*structs.Character, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+
+	character := &structs.Character{}
+	if err := json.Unmarshal(data, character); err != nil {
+		return nil, err
+	}
+	return character, nil
+}
+
+// reassignItemIDs gives every inventory item a fresh id, keeping parent links
+// and the references to them from the inventory and fast panel
+func reassignItemIDs(inventory *structs.CharacterInventory) {
+	ids := make(map[string]string, len(inventory.Items))
+	for _, item := range inventory.Items {
+		ids[item.ID] = tools.GenerateMongoId()
+	}
+
+	remap := func(id string) string {
+		if newID, ok := ids[id]; ok {
+			return newID
+		}
+		return id
+	}
+
+	for _, item := range inventory.Items {
+		item.ID = ids[item.ID]
+		item.ParentID = remap(item.ParentID)
+	}
+
+	inventory.Equipment = remap(inventory.Equipment)
+	inventory.Stash = remap(inventory.Stash)
+	inventory.SortingTable = remap(inventory.SortingTable)
+	inventory.QuestRaidItems = remap(inventory.QuestRaidItems)
+	inventory.QuestStashItems = remap(inventory.QuestStashItems)
+
+	for slot, id := range inventory.FastPanel {
+		if id, ok := id.(string); ok {
+			inventory.FastPanel[slot] = remap(id)
+		}
+	}
+}
+
+// newCharacterFromTemplate returns a copy of the character template of an
+// edition and side, with fresh item ids
+func newCharacterFromTemplate(editionName string, side string) (*structs.Character, error) {
+	edition, ok := Database.editions[editionName]
+	if !ok {
+		return nil, fmt.Errorf("unknown edition %s", editionName)
+	}
+
+	template := edition.bear
+	if side == SIDE_USEC {
+		template = edition.usec
+	}
+
+	character, err := cloneCharacter(template)
+	if err != nil {
+		return nil, fmt.Errorf("error cloning %s character of edition %s: %w", side, editionName, err)
+	}
+
+	reassignItemIDs(&character.Inventory)
+	return character, nil
+}
+
+// createCharacter creates the character of an account from the template of its
+// edition and writes it to the profile folder. Callers must hold the profile
+// lock, and profileNamesLock from checking the nickname until it returns.
+func createCharacter(profile *ProfileStruct, side string, nickname string, headID string, voiceID string) error {
+	character, err := newCharacterFromTemplate(profile.account.Edition, side)
+	if err != nil {
+		return err
+	}
+
+	edition := Database.editions[profile.account.Edition]
+	suites := edition.storage.Bear
+	if side == SIDE_USEC {
+		suites = edition.storage.Usec
+	}
+
+	profileID := profile.account.ID
+	character.ID = "pmc" + profileID
+	character.AID = profileID
+	character.Savage = "scav" + profileID
+
+	voice, _, _ := getCustomizationProps(voiceID, side)
+	character.Info.Nickname = nickname
+	character.Info.LowerNickname = strings.ToLower(nickname)
+	character.Info.Side = side
+	character.Info.Voice = voice
+	character.Info.RegistrationDate = time.Now().Unix()
+
+	if customization, ok := edition.customization[side]; ok {
+		character.Customization.Body = customization.Body
+		character.Customization.Feet = customization.Feet
+		character.Customization.Hands = customization.Hands
+	}
+	character.Customization.Head = headID
+
+	profile.character = character
+	profile.storage = &structs.Storage{ID: profileID, Suites: append([]string{}, suites...)}
+	profile.account.Wipe = false
+	setProfileNickname(profileID, nickname)
+	markProfileDirty(profile, PROFILE_ACCOUNT|PROFILE_CHARACTER|PROFILE_STORAGE)
+
+	return saveProfile(profileID)
+}
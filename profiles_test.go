@@ -0,0 +1,513 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TEST_DEADLOCK_TIMEOUT is how long concurrent requests get before a test
+// assumes they are deadlocked
+const TEST_DEADLOCK_TIMEOUT = 30 * time.Second
+
+// setupTestServer loads the game data profiles need into Database, points the
+// profile store at a temporary folder and returns the server routes
+func setupTestServer(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	initializeDatabaseMaps(&Database)
+	for _, load := range []func(*DatabaseStruct, tools.FileReader) error{setDatabaseCore, setEditions, setCustomization} {
+		if err := load(&Database, tools.ReadFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := newFilesystemProfileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	profileStoreLock.Lock()
+	profileStore = store
+	profileStoreLock.Unlock()
+	profileNicknames = make(map[string]string)
+	t.Cleanup(func() {
+		if err := closeProfileStore(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(databaseSnapshot())
+	r.Use(jsonContentTypeParser())
+	setGinRoutes(r)
+	return r
+}
+
+// newTestProfile creates an account without a character
+func newTestProfile(t *testing.T, username string) string {
+	t.Helper()
+
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+	profile, err := createProfile(username, "password", getEditionNames()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile.account.ID
+}
+
+// getTestCustomization returns a head and a voice a side can create a
+// character with
+func getTestCustomization(t *testing.T, side string) (string, string) {
+	t.Helper()
+
+	var head, voice string
+	for id := range Database.customization {
+		_, props, ok := getCustomizationProps(id, side)
+		if !ok {
+			continue
+		}
+		if props["BodyPart"] == "Head" {
+			head = id
+		} else {
+			voice = id
+		}
+	}
+	if head == "" || voice == "" {
+		t.Fatalf("no %s head and voice in customization.json", side)
+	}
+	return head, voice
+}
+
+// postClient sends a client request for a session and returns the error code
+// of the response envelope
+func postClient(t *testing.T, r *gin.Engine, sessionID string, path string, body interface{}) BackendErrorCode {
+	return postClientData(t, r, sessionID, path, body, nil)
+}
+
+// postClientData is postClient, also decoding the data of the response
+// envelope into data unless it is nil
+func postClientData(t *testing.T, r *gin.Engine, sessionID string, path string, body interface{}, data interface{}) BackendErrorCode {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Error(err)
+		return -1
+	}
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(encoded))
+	request.AddCookie(&http.Cookie{Name: SESSION_COOKIE, Value: sessionID})
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, request)
+
+	response := struct {
+		Err  BackendErrorCode `json:"err"`
+		Data json.RawMessage  `json:"data"`
+	}{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Errorf("invalid response from %s: %v", path, err)
+		return -1
+	}
+	if data != nil && response.Err == 0 {
+		if err := json.Unmarshal(response.Data, data); err != nil {
+			t.Errorf("invalid data from %s: %v", path, err)
+		}
+	}
+	return response.Err
+}
+
+// runConcurrently runs fn count times in parallel and fails the test if they
+// do not all return in time, as when requests deadlock
+func runConcurrently(t *testing.T, count int, fn func(i int)) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(TEST_DEADLOCK_TIMEOUT):
+		t.Fatal("concurrent requests did not finish, they are deadlocked")
+	}
+}
+
+func TestConcurrentProfileCreate(t *testing.T) {
+	r := setupTestServer(t)
+	head, voice := getTestCustomization(t, SIDE_BEAR)
+
+	const count = 8
+	sessions := make([]string, count)
+	for i := range sessions {
+		sessions[i] = newTestProfile(t, fmt.Sprintf("user%d", i))
+	}
+
+	codes := make([]BackendErrorCode, count)
+	runConcurrently(t, count, func(i int) {
+		body := ProfileCreateRequest{Side: SIDE_BEAR, Nickname: fmt.Sprintf("Player%d", i), HeadID: head, VoiceID: voice}
+		codes[i] = postClient(t, r, sessions[i], "/client/game/profile/create", body)
+		postClient(t, r, sessions[i], "/client/game/profile/list", nil)
+	})
+
+	for i, code := range codes {
+		if code != 0 {
+			t.Errorf("create of session %d failed with %d", i, code)
+		}
+		profile, ok := getProfile(sessions[i])
+		if !ok || profile.character == nil {
+			t.Errorf("session %d has no character", i)
+		}
+	}
+}
+
+func TestConcurrentProfileCreateSameNickname(t *testing.T) {
+	r := setupTestServer(t)
+	head, voice := getTestCustomization(t, SIDE_USEC)
+
+	const count = 8
+	sessions := make([]string, count)
+	for i := range sessions {
+		sessions[i] = newTestProfile(t, fmt.Sprintf("user%d", i))
+	}
+
+	codes := make([]BackendErrorCode, count)
+	runConcurrently(t, count, func(i int) {
+		body := ProfileCreateRequest{Side: SIDE_USEC, Nickname: []string{"Player", "PLAYER", "player"}[i%3], HeadID: head, VoiceID: voice}
+		codes[i] = postClient(t, r, sessions[i], "/client/game/profile/create", body)
+	})
+
+	created := 0
+	for i, code := range codes {
+		switch code {
+		case 0:
+			created++
+		case BACKEND_NICKNAME_NOT_UNIQUE:
+		default:
+			t.Errorf("create of session %d failed with %d", i, code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d characters were created with the same nickname, expected 1", created)
+	}
+}
+
+func TestConcurrentProfileImportSameID(t *testing.T) {
+	setupTestServer(t)
+	exportedID := newTestProfile(t, "exported")
+	exported, err := exportProfile(exportedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every archive claims the same unused id, so all but one must be remapped
+	const count = 8
+	sharedID := tools.GenerateMongoId()
+	archives := make([]*ProfileArchive, count)
+	for i := range archives {
+		archive := &ProfileArchive{}
+		if err := json.Unmarshal(data, archive); err != nil {
+			t.Fatal(err)
+		}
+		remapProfileID(archive.Files, exportedID, sharedID)
+		archive.Files[ACCOUNT_FILE]["username"] = fmt.Sprintf("imported%d", i)
+		archives[i] = archive
+	}
+
+	profileIDs := make([]string, count)
+	runConcurrently(t, count, func(i int) {
+		databaseLock.RLock()
+		defer databaseLock.RUnlock()
+
+		profileID, err := importProfile(archives[i])
+		if err != nil {
+			t.Errorf("import %d failed: %v", i, err)
+		}
+		profileIDs[i] = profileID
+	})
+
+	store, err := getProfileStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := make(map[string]bool)
+	for i, profileID := range profileIDs {
+		if imported[profileID] {
+			t.Errorf("import %d reused profile id %s", i, profileID)
+		}
+		imported[profileID] = true
+
+		profile, ok := getProfile(profileID)
+		if !ok || profile.account.Username != fmt.Sprintf("imported%d", i) {
+			t.Errorf("import %d is not registered as %s", i, profileID)
+		}
+		read := func(file string) ([]byte, error) { return store.Read(profileID, file) }
+		account := &structs.Account{}
+		if err := readProfileFileInto(read, profileID, ACCOUNT_FILE, account); err != nil {
+			t.Fatal(err)
+		}
+		if account.Username != fmt.Sprintf("imported%d", i) {
+			t.Errorf("import %d was overwritten by %s", i, account.Username)
+		}
+	}
+}
+
+func TestConcurrentSessionRequests(t *testing.T) {
+	r := setupTestServer(t)
+	head, voice := getTestCustomization(t, SIDE_BEAR)
+	sessionID := newTestProfile(t, "user")
+
+	const count = 8
+	codes := make([]BackendErrorCode, count)
+	runConcurrently(t, count, func(i int) {
+		body := ProfileCreateRequest{Side: SIDE_BEAR, Nickname: fmt.Sprintf("Player%d", i), HeadID: head, VoiceID: voice}
+		codes[i] = postClient(t, r, sessionID, "/client/game/profile/create", body)
+		postClient(t, r, sessionID, "/client/game/config", nil)
+	})
+
+	created := 0
+	for i, code := range codes {
+		switch code {
+		case 0:
+			created++
+		case BACKEND_BAD_REQUEST:
+		default:
+			t.Errorf("create %d failed with %d", i, code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d characters were created for one session, expected 1", created)
+	}
+
+	profileNamesLock.Lock()
+	defer profileNamesLock.Unlock()
+	if len(profileNicknames) != 1 {
+		t.Errorf("%d nicknames are claimed for one session, expected 1", len(profileNicknames))
+	}
+}
+
+func TestProfileCreateDoesNotWaitForOtherSessions(t *testing.T) {
+	r := setupTestServer(t)
+	head, voice := getTestCustomization(t, SIDE_BEAR)
+	sessionID := newTestProfile(t, "user")
+	busyID := newTestProfile(t, "busy")
+
+	// a request of the other session holds its lock throughout
+	busy, ok := lockProfile(busyID)
+	if !ok {
+		t.Fatal("unknown profile " + busyID)
+	}
+	defer busy.lock.Unlock()
+
+	runConcurrently(t, 1, func(int) {
+		body := ProfileCreateRequest{Side: SIDE_BEAR, Nickname: "Player", HeadID: head, VoiceID: voice}
+		if code := postClient(t, r, sessionID, "/client/game/profile/create", body); code != 0 {
+			t.Errorf("create failed with %d", code)
+		}
+	})
+}
+
+// Items of the item event test
+const (
+	testTraderID  string = "5a7c2eca46aef81a7ca2145d"
+	testAssortID  string = "5a7c2eca46aef81a7ca21450"
+	testBoughtTpl string = "5448fee04bdc2dbc018b4567"
+	testMovedTpl  string = "5449016a4bdc2d6f028b4560"
+	testItemPrice int    = 100
+)
+
+// setupTestTrading gives every item of a character a 1x1 template, with a
+// stash grid, and adds a trader selling testBoughtTpl for roubles. items.json
+// is not bundled, so the templates are made up for the test.
+func setupTestTrading(character *structs.Character) {
+	for _, item := range character.Inventory.Items {
+		Database.items[item.Tpl] = &structs.DatabaseItem{ID: item.Tpl, Props: structs.ItemProps{Width: 1, Height: 1}}
+	}
+	stash := getTestItem(character, character.Inventory.Stash)
+	Database.items[stash.Tpl].Props.Grids = []structs.ItemGrid{
+		{Name: "hideout", Props: structs.ItemGridsProps{CellsH: 10, CellsV: 30}},
+	}
+	Database.items[ROUBLES_TPL] = &structs.DatabaseItem{ID: ROUBLES_TPL, Props: structs.ItemProps{Width: 1, Height: 1, StackMaxSize: 1000000000}}
+	Database.items[testBoughtTpl] = &structs.DatabaseItem{ID: testBoughtTpl, Props: structs.ItemProps{Width: 1, Height: 1, StackMaxSize: 1}}
+	Database.items[testMovedTpl] = &structs.DatabaseItem{ID: testMovedTpl, Props: structs.ItemProps{Width: 1, Height: 1}}
+
+	Database.traders[testTraderID] = &TraderStruct{
+		base: &structs.TraderBase{
+			ID:            testTraderID,
+			Currency:      "RUB",
+			LoyaltyLevels: []*structs.TraderLoyaltyLevel{{}},
+			NextResupply:  int(time.Now().Add(time.Hour).Unix()),
+		},
+		baseAssort: &structs.Assort{
+			Items: []*structs.InventoryItem{{
+				ID:       testAssortID,
+				Tpl:      testBoughtTpl,
+				ParentID: "hideout",
+				SlotID:   "hideout",
+				Upd:      &structs.ItemUpd{StackObjectsCount: 1000},
+			}},
+			BarterScheme: map[string][][]*structs.BarterItem{
+				testAssortID: {{{Tpl: ROUBLES_TPL, Count: float64(testItemPrice)}}},
+			},
+			LoyalLevelItems: map[string]int{testAssortID: 1},
+		},
+	}
+	traderStockLock.Lock()
+	delete(traderStockBought, testTraderID)
+	traderStockLock.Unlock()
+}
+
+// getTestItem returns the item of a character with id
+func getTestItem(character *structs.Character, id string) *structs.InventoryItem {
+	for _, item := range character.Inventory.Items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// getTestStack returns the stash item of a character holding tpl
+func getTestStack(character *structs.Character, tpl string) *structs.InventoryItem {
+	for _, item := range character.Inventory.Items {
+		if item.Tpl == tpl && item.ParentID == character.Inventory.Stash {
+			return item
+		}
+	}
+	return nil
+}
+
+// postItemEvents sends one action to items/moving and fails the test if it
+// is not applied
+func postItemEvents(t *testing.T, r *gin.Engine, sessionID string, action interface{}) {
+	data, err := json.Marshal(action)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	response := ItemEventResponse{}
+	body := ItemEventRequest{Data: []json.RawMessage{data}}
+	if code := postClientData(t, r, sessionID, "/client/game/profile/items/moving", body, &response); code != 0 {
+		t.Errorf("items/moving failed with %d", code)
+	}
+	for _, warning := range response.Warnings {
+		t.Errorf("action %s was refused: %s", data, warning.ErrMsg)
+	}
+}
+
+func TestConcurrentItemEvents(t *testing.T) {
+	r := setupTestServer(t)
+	head, voice := getTestCustomization(t, SIDE_BEAR)
+	sessionID := newTestProfile(t, "user")
+
+	body := ProfileCreateRequest{Side: SIDE_BEAR, Nickname: "Player", HeadID: head, VoiceID: voice}
+	if code := postClient(t, r, sessionID, "/client/game/profile/create", body); code != 0 {
+		t.Fatalf("create failed with %d", code)
+	}
+
+	profile, ok := lockProfile(sessionID)
+	if !ok {
+		t.Fatal("unknown profile " + sessionID)
+	}
+	character := profile.character
+	setupTestTrading(character)
+	stashID := character.Inventory.Stash
+	roubles := getTestStack(character, ROUBLES_TPL)
+	if roubles == nil {
+		profile.lock.Unlock()
+		t.Fatal("the character has no roubles in the stash")
+	}
+	roublesID, balance := roubles.ID, getStackCount(roubles)
+	itemCount := len(character.Inventory.Items)
+
+	// the moved item goes back and forth between two cells at the bottom of
+	// the stash, out of the way of the bought items
+	cells := []structs.ItemLocation{{X: 9, Y: 29}, {X: 8, Y: 29}}
+	moved := &structs.InventoryItem{ID: tools.GenerateMongoId(), Tpl: testMovedTpl, ParentID: stashID, SlotID: "hideout", Location: &cells[0]}
+	character.Inventory.Items = append(character.Inventory.Items, moved)
+	profile.lock.Unlock()
+
+	const count = 8
+	const rounds = 5
+	runConcurrently(t, count, func(i int) {
+		for round := 0; round < rounds; round++ {
+			if (i+round)%2 == 0 {
+				location := cells[(i+round)/2%2]
+				postItemEvents(t, r, sessionID, map[string]interface{}{
+					"Action": "Move",
+					"item":   moved.ID,
+					"to":     ItemEventTarget{ID: stashID, Container: "hideout", Location: &location},
+				})
+				continue
+			}
+			postItemEvents(t, r, sessionID, map[string]interface{}{
+				"Action":       "TradingConfirm",
+				"type":         TRADE_BUY,
+				"tid":          testTraderID,
+				"item_id":      testAssortID,
+				"count":        1,
+				"scheme_id":    0,
+				"scheme_items": []TradeSchemeItem{{ID: roublesID, Count: testItemPrice}},
+			})
+			postClient(t, r, sessionID, "/client/game/profile/list", nil)
+		}
+	})
+
+	profile, ok = lockProfile(sessionID)
+	if !ok {
+		t.Fatal("unknown profile " + sessionID)
+	}
+	defer profile.lock.Unlock()
+	character = profile.character
+
+	bought := 0
+	for _, item := range character.Inventory.Items {
+		if item.Tpl == testBoughtTpl {
+			bought++
+		}
+	}
+	buys := count * rounds / 2
+	if bought != buys {
+		t.Errorf("%d items were bought, expected %d", bought, buys)
+	}
+	if items := len(character.Inventory.Items); items != itemCount+1+buys {
+		t.Errorf("the inventory has %d items, expected %d", items, itemCount+1+buys)
+	}
+
+	roubles = getTestItem(character, roublesID)
+	if expected := balance - buys*testItemPrice; roubles == nil || getStackCount(roubles) != expected {
+		t.Errorf("the roubles stack is %v, expected %d", roubles, expected)
+	}
+
+	item := getTestItem(character, moved.ID)
+	if item == nil || item.ParentID != stashID || item.Location == nil || item.Location.Y != 29 || (item.Location.X != 8 && item.Location.X != 9) {
+		t.Errorf("the moved item is at %+v, expected one of %+v", item, cells)
+	}
+	traderStockLock.Lock()
+	defer traderStockLock.Unlock()
+	if sold := traderStockBought[testTraderID][testAssortID]; sold != buys {
+		t.Errorf("the trader sold %d, expected %d", sold, buys)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"MT-GO/tools"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Profile store backends selectable with "profileStore" in server.json
+const (
+	PROFILE_STORE_FILESYSTEM string = "filesystem"
+	PROFILE_STORE_BOLT       string = "bolt"
+
+	DEFAULT_BOLT_STORE_PATH string = USER_FILE_PATH + "/profiles.db"
+)
+
+// ProfileStore keeps the files of every profile and of their backups. Files
+// are addressed by their name in a profile folder, such as account.json.
+// Reading a missing profile or file returns an error wrapping fs.ErrNotExist.
+type ProfileStore interface {
+	// List returns the id of every profile
+	List() ([]string, error)
+	Exists(profileID string) (bool, error)
+	Read(profileID string, file string) ([]byte, error)
+	// Write creates or replaces files of a profile, creating the profile if needed
+	Write(profileID string, files map[string][]byte) error
+	// Remove deletes a profile and its backups
+	Remove(profileID string) error
+
+	// Backups returns the names of the backups of a profile, in no order
+	Backups(profileID string) ([]string, error)
+	ReadBackup(profileID string, backup string, file string) ([]byte, error)
+	WriteBackup(profileID string, backup string, files map[string][]byte) error
+	RemoveBackup(profileID string, backup string) error
+
+	Close() error
+}
+
+var (
+	profileStore     ProfileStore
+	profileStoreLock sync.Mutex
+)
+
+// getProfileStore returns the store selected in server.json, opening it on
+// first use. server.json is read directly because profiles load concurrently
+// with the core database.
+func getProfileStore() (ProfileStore, error) {
+	profileStoreLock.Lock()
+	defer profileStoreLock.Unlock()
+
+	if profileStore != nil {
+		return profileStore, nil
+	}
+
+	kind, path := PROFILE_STORE_FILESYSTEM, ""
+	if serverConfig, err := tools.ReadParsed(SERVER_CONFIG_PATH); err == nil {
+		config, _ := serverConfig.(map[string]interface{})["profileStore"].(map[string]interface{})
+		if value, ok := config["type"].(string); ok {
+			kind = value
+		}
+		if value, ok := config["path"].(string); ok {
+			path = value
+		}
+	}
+
+	store, err := newProfileStore(kind, path)
+	if err != nil {
+		return nil, err
+	}
+	profileStore = store
+	return store, nil
+}
+
+// closeProfileStore closes the store if it was opened
+func closeProfileStore() error {
+	profileStoreLock.Lock()
+	defer profileStoreLock.Unlock()
+
+	if profileStore == nil {
+		return nil
+	}
+	err := profileStore.Close()
+	profileStore = nil
+	return err
+}
+
+// newProfileStore opens a store backend, at its default path if path is empty
+func newProfileStore(kind string, path string) (ProfileStore, error) {
+	switch kind {
+	case PROFILE_STORE_FILESYSTEM:
+		if path == "" {
+			path = PROFILES_FILE_PATH
+		}
+		return newFilesystemProfileStore(path)
+	case PROFILE_STORE_BOLT:
+		if path == "" {
+			path = DEFAULT_BOLT_STORE_PATH
+		}
+		return newBoltProfileStore(path)
+	default:
+		return nil, fmt.Errorf("unknown profile store %s, expected %s or %s", kind, PROFILE_STORE_FILESYSTEM, PROFILE_STORE_BOLT)
+	}
+}
+
+// filesystemProfileStore keeps each profile in a folder of JSON files, with
+// its backups in a backups folder inside it
+type filesystemProfileStore struct {
+	path string
+}
+
+func newFilesystemProfileStore(path string) (*filesystemProfileStore, error) {
+	if err := os.MkdirAll(tools.GetAbsolutePathFrom(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating profiles directory: %w", err)
+	}
+	return &filesystemProfileStore{path: path}, nil
+}
+
+func (s *filesystemProfileStore) profilePath(profileID string) string {
+	return tools.GetAbsolutePathFrom(filepath.Join(s.path, profileID))
+}
+
+func (s *filesystemProfileStore) backupPath(profileID string, backup string) string {
+	return filepath.Join(s.profilePath(profileID), BACKUPS_DIRECTORY, backup)
+}
+
+func (s *filesystemProfileStore) List() ([]string, error) {
+	return tools.GetDirectoriesFrom(s.path)
+}
+
+func (s *filesystemProfileStore) Exists(profileID string) (bool, error) {
+	_, err := os.Stat(s.profilePath(profileID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *filesystemProfileStore) Read(profileID string, file string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.profilePath(profileID), file))
+}
+
+func (s *filesystemProfileStore) Write(profileID string, files map[string][]byte) error {
+	return writeFilesTo(s.profilePath(profileID), files)
+}
+
+func (s *filesystemProfileStore) Remove(profileID string) error {
+	return os.RemoveAll(s.profilePath(profileID))
+}
+
+func (s *filesystemProfileStore) Backups(profileID string) ([]string, error) {
+	path := filepath.Join(s.profilePath(profileID), BACKUPS_DIRECTORY)
+	if !tools.FileExist(path) {
+		return []string{}, nil
+	}
+	return tools.GetDirectoriesFrom(path)
+}
+
+func (s *filesystemProfileStore) ReadBackup(profileID string, backup string, file string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.backupPath(profileID, backup), file))
+}
+
+func (s *filesystemProfileStore) WriteBackup(profileID string, backup string, files map[string][]byte) error {
+	return writeFilesTo(s.backupPath(profileID, backup), files)
+}
+
+func (s *filesystemProfileStore) RemoveBackup(profileID string, backup string) error {
+	return os.RemoveAll(s.backupPath(profileID, backup))
+}
+
+func (s *filesystemProfileStore) Close() error {
+	return nil
+}
+
+// writeFilesTo replaces each of files in a directory, creating it if needed.
+// Each file is replaced atomically, but the set is not: a crash partway
+// through can leave some files from this write and others from the last one.
+// The bolt store writes a set in one transaction.
+func writeFilesTo(path string, files map[string][]byte) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := tools.WriteToFile(filepath.Join(path, name), string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTempFiles deletes the temporary files of writes that never finished,
+// left in profile and backup folders when the server stopped mid-write. Only
+// the server calls it, before it writes anything.
+func (s *filesystemProfileStore) removeTempFiles() error {
+	root := tools.GetAbsolutePathFrom(s.path)
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name := entry.Name()
+		if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		log.Printf("Removed %s, left by an unfinished write", path)
+		return nil
+	})
+}
+
+// boltProfileStore keeps every profile in one embedded database file. Each
+// profile is a bucket of files under PROFILES_BUCKET, with a nested bucket
+// of backups. A Write is one transaction, so its files change together.
+type boltProfileStore struct {
+	db *bolt.DB
+}
+
+var (
+	PROFILES_BUCKET = []byte("profiles")
+	BACKUPS_BUCKET  = []byte(BACKUPS_DIRECTORY)
+)
+
+func newBoltProfileStore(path string) (*boltProfileStore, error) {
+	path = tools.GetAbsolutePathFrom(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating profile store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening profile store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(PROFILES_BUCKET)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initializing profile store %s: %w", path, err)
+	}
+	return &boltProfileStore{db: db}, nil
+}
+
+// notExist reports a missing profile, backup or file
+func notExist(name string) error {
+	return fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+}
+
+// readBucketFile copies a file out of a bucket, as values are only valid
+// during their transaction
+func readBucketFile(bucket *bolt.Bucket, name string, file string) ([]byte, error) {
+	if bucket == nil {
+		return nil, notExist(name)
+	}
+	data := bucket.Get([]byte(file))
+	if data == nil {
+		return nil, notExist(name + "/" + file)
+	}
+	return append([]byte{}, data...), nil
+}
+
+func writeBucketFiles(bucket *bolt.Bucket, files map[string][]byte) error {
+	for name, data := range files {
+		if err := bucket.Put([]byte(name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltProfileStore) List() ([]string, error) {
+	profileIDs := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(PROFILES_BUCKET).ForEach(func(key []byte, value []byte) error {
+			if value == nil {
+				profileIDs = append(profileIDs, string(key))
+			}
+			return nil
+		})
+	})
+	return profileIDs, err
+}
+
+func (s *boltProfileStore) Exists(profileID string) (bool, error) {
+	exists := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(PROFILES_BUCKET).Bucket([]byte(profileID)) != nil
+		return nil
+	})
+	return exists, err
+}
+
+func (s *boltProfileStore) Read(profileID string, file string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		data, err = readBucketFile(tx.Bucket(PROFILES_BUCKET).Bucket([]byte(profileID)), profileID, file)
+		return err
+	})
+	return data, err
+}
+
+func (s *boltProfileStore) Write(profileID string, files map[string][]byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		profile, err := tx.Bucket(PROFILES_BUCKET).CreateBucketIfNotExists([]byte(profileID))
+		if err != nil {
+			return err
+		}
+		return writeBucketFiles(profile, files)
+	})
+}
+
+func (s *boltProfileStore) Remove(profileID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(PROFILES_BUCKET).DeleteBucket([]byte(profileID))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// backupsBucket returns the bucket holding the backups of a profile, if any
+func backupsBucket(tx *bolt.Tx, profileID string) *bolt.Bucket {
+	profile := tx.Bucket(PROFILES_BUCKET).Bucket([]byte(profileID))
+	if profile == nil {
+		return nil
+	}
+	return profile.Bucket(BACKUPS_BUCKET)
+}
+
+func (s *boltProfileStore) Backups(profileID string) ([]string, error) {
+	backups := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := backupsBucket(tx, profileID)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key []byte, value []byte) error {
+			if value == nil {
+				backups = append(backups, string(key))
+			}
+			return nil
+		})
+	})
+	return backups, err
+}
+
+func (s *boltProfileStore) ReadBackup(profileID string, backup string, file string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := backupsBucket(tx, profileID)
+		if bucket == nil {
+			return notExist(profileID + "/" + backup)
+		}
+		var err error
+		data, err = readBucketFile(bucket.Bucket([]byte(backup)), profileID+"/"+backup, file)
+		return err
+	})
+	return data, err
+}
+
+func (s *boltProfileStore) WriteBackup(profileID string, backup string, files map[string][]byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		profile, err := tx.Bucket(PROFILES_BUCKET).CreateBucketIfNotExists([]byte(profileID))
+		if err != nil {
+			return err
+		}
+		backups, err := profile.CreateBucketIfNotExists(BACKUPS_BUCKET)
+		if err != nil {
+			return err
+		}
+		bucket, err := backups.CreateBucketIfNotExists([]byte(backup))
+		if err != nil {
+			return err
+		}
+		return writeBucketFiles(bucket, files)
+	})
+}
+
+func (s *boltProfileStore) RemoveBackup(profileID string, backup string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := backupsBucket(tx, profileID)
+		if bucket == nil {
+			return nil
+		}
+		err := bucket.DeleteBucket([]byte(backup))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (s *boltProfileStore) Close() error {
+	return s.db.Close()
+}
+
+// copyProfileStore copies every profile and backup from one store to another
+func copyProfileStore(from ProfileStore, to ProfileStore) (int, error) {
+	profileIDs, err := from.List()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, profileID := range profileIDs {
+		files, err := readProfileFiles(func(file string) ([]byte, error) { return from.Read(profileID, file) })
+		if err != nil {
+			return 0, fmt.Errorf("error reading profile %s: %w", profileID, err)
+		}
+		if err := to.Write(profileID, files); err != nil {
+			return 0, fmt.Errorf("error writing profile %s: %w", profileID, err)
+		}
+
+		backups, err := from.Backups(profileID)
+		if err != nil {
+			return 0, fmt.Errorf("error reading backups of profile %s: %w", profileID, err)
+		}
+		for _, backup := range backups {
+			files, err := readProfileFiles(func(file string) ([]byte, error) { return from.ReadBackup(profileID, backup, file) })
+			if err != nil {
+				return 0, fmt.Errorf("error reading backup %s of profile %s: %w", backup, profileID, err)
+			}
+			if err := to.WriteBackup(profileID, backup, files); err != nil {
+				return 0, fmt.Errorf("error writing backup %s of profile %s: %w", backup, profileID, err)
+			}
+		}
+	}
+	return len(profileIDs), nil
+}
+
+// readProfileFiles reads every file a profile has, skipping missing ones
+func readProfileFiles(read profileFileReader) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, file := range profileFileNames {
+		data, err := read(file.name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files[file.name] = data
+	}
+	return files, nil
+}
+
+// migrateStoreCommand copies every profile, with its backups, from the
+// configured store to another backend while the server is stopped:
+//
+//	MT-GO migrate-store -to bolt [-path user/profiles.db]
+func migrateStoreCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate-store", flag.ContinueOnError)
+	kind := flags.String("to", "", "backend to copy profiles to: filesystem or bolt")
+	path := flags.String("path", "", "path of the destination store, its default if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *kind == "" {
+		return fmt.Errorf("usage: migrate-store -to <filesystem|bolt> [-path <path>]")
+	}
+
+	from, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+	to, err := newProfileStore(*kind, *path)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	count, err := copyProfileStore(from, to)
+	if err != nil {
+		return err
+	}
+	log.Printf("Copied %d profiles to the %s store, set \"profileStore\" in server.json to use it", count, *kind)
+	return nil
+}
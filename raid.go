@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+)
+
+// RaidProfileJSON is the serialized RaidProfileStruct of a profile
+type RaidProfileJSON struct {
+	LastLocation string `json:"lastLocation"`
+	Insurance    bool   `json:"insurance"`
+	CarExtracts  int    `json:"carExtracts"`
+}
+
+func raidProfileToJSON(raid RaidProfileStruct) RaidProfileJSON {
+	return RaidProfileJSON{
+		LastLocation: raid.lastLocation.name,
+		Insurance:    raid.lastLocation.insurance,
+		CarExtracts:  raid.carExtracts,
+	}
+}
+
+func raidProfileFromJSON(raid RaidProfileJSON) RaidProfileStruct {
+	return RaidProfileStruct{
+		lastLocation: RaidLocationStruct{name: raid.LastLocation, insurance: raid.Insurance},
+		carExtracts:  raid.CarExtracts,
+	}
+}
+
+// setRaid reads the raid state of a profile, which is empty for profiles that
+// have not finished a raid yet
+func setRaid(read profileFileReader, profileID string) RaidProfileStruct {
+	raid := RaidProfileJSON{}
+	err := readProfileFileInto(read, profileID, RAID_FILE, &raid)
+	if errors.Is(err, fs.ErrNotExist) {
+		return RaidProfileStruct{}
+	} else if err != nil {
+		log.Printf("Error reading raid.json for profile %s: %v", profileID, err)
+		return RaidProfileStruct{}
+	}
+	return raidProfileFromJSON(raid)
+}
+
+// setLastLocation records the location a profile is going into a raid on,
+// and whether items lost there are insured. carExtracts is kept as saved
+// until the server handles the end of raids.
+func setLastLocation(profile *ProfileStruct, name string) error {
+	var location *LocationStruct
+	for id, candidate := range Database.locations.locations {
+		if strings.EqualFold(id, name) || strings.EqualFold(candidate.base.ID, name) {
+			location = candidate
+			break
+		}
+	}
+	if location == nil {
+		return fmt.Errorf("unknown location %s", name)
+	}
+
+	profile.raid.lastLocation = RaidLocationStruct{name: location.base.ID, insurance: location.base.Insurance}
+	markProfileDirty(profile, PROFILE_RAID)
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"MT-GO/plugins"
+	"MT-GO/tools"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DATABASE_WATCH_INTERVAL = 2 * time.Second
+
+// databaseLock guards the top-level fields of Database. Every request holds a
+// read lock for its whole duration, so it sees one consistent snapshot while
+// a reload swaps new data in.
+var databaseLock sync.RWMutex
+
+// reloadLock serializes reloads so two of them never build on the same copy
+var reloadLock sync.Mutex
+
+// databaseSnapshot keeps Database from being swapped while a request runs
+func databaseSnapshot() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		databaseLock.RLock()
+		defer databaseLock.RUnlock()
+		c.Next()
+	}
+}
+
+// databaseModOverlay is the mod overlay the loaded database was read through.
+// Reloads read through it too, and reloadMods replaces it. Guarded by reloadLock
+// once the database is loaded.
+var databaseModOverlay modOverlay
+
+// reloadDatabase re-runs the named loader into a fresh DatabaseStruct and only
+// swaps the result in if it loaded without error.
+func reloadDatabase(name string) error {
+	loader, ok := getDatabaseLoader(name)
+	if !ok {
+		return fmt.Errorf("unknown database subsystem %s", name)
+	}
+	if loader.apply == nil {
+		return fmt.Errorf("database subsystem %s cannot be reloaded", name)
+	}
+
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	start := time.Now()
+	if err := reloadLoaders([]databaseLoader{loader}, databaseModOverlay); err != nil {
+		return err
+	}
+
+	log.Printf("Reloaded %s in %s", name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// reloadMods rebuilds the mod overlay from mods/ and reloads every subsystem
+// with a file patched by the old or the new overlay. Neither the overlay nor
+// any subsystem is swapped in unless all of them load.
+func reloadMods() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	start := time.Now()
+	overlay, err := loadModOverlay()
+	if err != nil {
+		return fmt.Errorf("error loading mods: %w", err)
+	}
+
+	var loaders []databaseLoader
+	for _, loader := range databaseLoaders {
+		if loader.apply != nil && (databaseModOverlay.patches(loader) || overlay.patches(loader)) {
+			loaders = append(loaders, loader)
+		}
+	}
+	if err := reloadLoaders(loaders, overlay); err != nil {
+		return err
+	}
+	databaseModOverlay = overlay
+
+	log.Printf("Reloaded mods and %d subsystems in %s", len(loaders), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// reloadLoaders runs loaders into a fresh DatabaseStruct, reading through
+// overlay, and applies them all to Database at once. Callers hold reloadLock.
+func reloadLoaders(loaders []databaseLoader, overlay modOverlay) error {
+	next := DatabaseStruct{}
+	initializeDatabaseMaps(&next)
+	for _, loader := range loaders {
+		if err := loader.load(&next, overlay.read); err != nil {
+			return fmt.Errorf("error loading %s: %w", loader.name, err)
+		}
+	}
+
+	databaseLock.Lock()
+	defer databaseLock.Unlock()
+	for _, loader := range loaders {
+		loader.apply(&Database, &next)
+	}
+	plugins.DatabaseLoaded(&pluginDatabase{&Database})
+	return nil
+}
+
+func getDatabaseLoader(name string) (databaseLoader, bool) {
+	for _, loader := range databaseLoaders {
+		if loader.name == name {
+			return loader, true
+		}
+	}
+	return databaseLoader{}, false
+}
+
+// databaseFingerprint changes whenever a file under a loader's paths is
+// added, removed or modified
+type databaseFingerprint struct {
+	files   int
+	size    int64
+	modTime time.Time
+}
+
+func getDatabaseFingerprint(paths []string) databaseFingerprint {
+	fingerprint := databaseFingerprint{}
+	for _, path := range paths {
+		files, size := tools.GetDirectorySize(path)
+		fingerprint.files += files
+		fingerprint.size += size
+		if modTime := tools.GetLastModified(path); modTime.After(fingerprint.modTime) {
+			fingerprint.modTime = modTime
+		}
+	}
+	return fingerprint
+}
+
+// fingerprintWatch tracks the fingerprints of watched paths by name
+type fingerprintWatch struct {
+	loaded  map[string]databaseFingerprint
+	pending map[string]databaseFingerprint
+}
+
+// settled reports whether the fingerprint of name has changed since it was
+// last loaded and then stayed unchanged for one interval, so a reload never
+// reads a file that is still being written
+func (w *fingerprintWatch) settled(name string, current databaseFingerprint) bool {
+	if current == w.loaded[name] {
+		delete(w.pending, name)
+		return false
+	}
+	if previous, ok := w.pending[name]; !ok || previous != current {
+		w.pending[name] = current
+		return false
+	}
+
+	delete(w.pending, name)
+	w.loaded[name] = current
+	return true
+}
+
+// watchDatabase polls the database and mods directories. A changed subsystem
+// is reloaded, and a change under mods/ rebuilds the mod overlay and reloads
+// the subsystems it patches.
+func watchDatabase() {
+	watch := fingerprintWatch{
+		loaded:  make(map[string]databaseFingerprint),
+		pending: make(map[string]databaseFingerprint),
+	}
+	modsPaths := []string{MODS_FILE_PATH}
+	watch.loaded[MODS_FILE_PATH] = getDatabaseFingerprint(modsPaths)
+	for _, loader := range databaseLoaders {
+		if loader.apply != nil {
+			watch.loaded[loader.name] = getDatabaseFingerprint(loader.paths)
+		}
+	}
+
+	ticker := time.NewTicker(DATABASE_WATCH_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if watch.settled(MODS_FILE_PATH, getDatabaseFingerprint(modsPaths)) {
+			if err := reloadMods(); err != nil {
+				log.Printf("Reload of mods rejected, keeping the loaded data: %v", err)
+			}
+		}
+
+		for _, loader := range databaseLoaders {
+			if loader.apply == nil || !watch.settled(loader.name, getDatabaseFingerprint(loader.paths)) {
+				continue
+			}
+			if err := reloadDatabase(loader.name); err != nil {
+				log.Printf("Reload of %s rejected, keeping the loaded data: %v", loader.name, err)
+			}
+		}
+	}
+}
+
+// isHotReloadEnabled reads hotReload from server.json
+func isHotReloadEnabled() bool {
+	databaseLock.RLock()
+	defer databaseLock.RUnlock()
+
+	enabled, _ := Database.core.serverConfig["hotReload"].(bool)
+	return enabled
+}
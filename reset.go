@@ -0,0 +1,284 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Parts of a character that can be reset to the edition template. RESET_WIPE
+// resets the whole character, keeping only its identity and customization.
+const (
+	RESET_WIPE    string = "wipe"
+	RESET_STASH   string = "stash"
+	RESET_QUESTS  string = "quests"
+	RESET_HIDEOUT string = "hideout"
+	RESET_TRADERS string = "traders"
+	RESET_SKILLS  string = "skills"
+)
+
+// profileResets copy one part of the template onto a character and describe
+// what changed
+var profileResets = map[string]func(character *structs.Character, template *structs.Character) string{
+	RESET_STASH: func(character *structs.Character, template *structs.Character) string {
+		before := len(character.Inventory.Items)
+		character.Inventory = template.Inventory
+		character.InsuredItems = template.InsuredItems
+		return fmt.Sprintf("%d items to %d", before, len(character.Inventory.Items))
+	},
+	RESET_QUESTS: func(character *structs.Character, template *structs.Character) string {
+		before := len(character.Quests)
+		character.Quests = template.Quests
+		character.ConditionCounters = template.ConditionCounters
+		character.BackendCounters = template.BackendCounters
+		return fmt.Sprintf("%d quests to %d", before, len(character.Quests))
+	},
+	RESET_HIDEOUT: func(character *structs.Character, template *structs.Character) string {
+		before := countBuiltAreas(character.Hideout.Areas)
+		character.Hideout = template.Hideout
+		character.Bonuses = template.Bonuses
+		return fmt.Sprintf("%d areas built to %d", before, countBuiltAreas(character.Hideout.Areas))
+	},
+	RESET_TRADERS: func(character *structs.Character, template *structs.Character) string {
+		before := len(character.TradersInfo)
+		character.TradersInfo = template.TradersInfo
+		return fmt.Sprintf("%d trader standings to %d", before, len(character.TradersInfo))
+	},
+	RESET_SKILLS: func(character *structs.Character, template *structs.Character) string {
+		before := countTrainedSkills(character.Skills.Common)
+		character.Skills = template.Skills
+		return fmt.Sprintf("%d trained skills to %d", before, countTrainedSkills(character.Skills.Common))
+	},
+}
+
+func countBuiltAreas(areas []*structs.CharacterHideoutArea) int {
+	built := 0
+	for _, area := range areas {
+		if area.Level > 0 {
+			built++
+		}
+	}
+	return built
+}
+
+func countTrainedSkills(skills []*structs.CharacterSkill) int {
+	trained := 0
+	for _, skill := range skills {
+		if skill.Progress > 0 {
+			trained++
+		}
+	}
+	return trained
+}
+
+// ProfileResetSummary is what a reset changed on one profile
+type ProfileResetSummary struct {
+	ProfileID string            `json:"profileId"`
+	Nickname  string            `json:"nickname"`
+	Reset     map[string]string `json:"reset"`
+	Backup    string            `json:"backup"`
+}
+
+func (s *ProfileResetSummary) String() string {
+	parts := make([]string, 0, len(s.Reset))
+	for part := range s.Reset {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Profile %s (%s), backup %s\n", s.ProfileID, s.Nickname, s.Backup)
+	for _, part := range parts {
+		fmt.Fprintf(&builder, "  %s: %s\n", part, s.Reset[part])
+	}
+	return builder.String()
+}
+
+// validateResetParts rejects unknown parts and mixing a wipe with parts
+func validateResetParts(parts []string) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("nothing to reset")
+	}
+	for _, part := range parts {
+		if part == RESET_WIPE {
+			if len(parts) > 1 {
+				return fmt.Errorf("%s already resets every part", RESET_WIPE)
+			}
+			continue
+		}
+		if _, ok := profileResets[part]; !ok {
+			return fmt.Errorf("unknown reset %s", part)
+		}
+	}
+	return nil
+}
+
+// resetProfile resets parts of the character of a profile to its edition
+// template, after backing it up. Account, storage, dialogues and the chosen
+// customization are kept. It waits for requests using the profile to finish;
+// later ones get the reset profile. Callers must hold databaseLock.
+func resetProfile(profileID string, parts []string) (*ProfileResetSummary, error) {
+	if err := validateResetParts(parts); err != nil {
+		return nil, err
+	}
+
+	profile, ok := lockProfile(profileID)
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %s", profileID)
+	}
+	defer profile.lock.Unlock()
+	if profile.account == nil || profile.character == nil {
+		return nil, fmt.Errorf("profile %s has no character", profileID)
+	}
+
+	current := profile.character
+	template, err := newCharacterFromTemplate(profile.account.Edition, current.Info.Side)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ProfileResetSummary{
+		ProfileID: profileID,
+		Nickname:  current.Info.Nickname,
+		Reset:     make(map[string]string),
+	}
+
+	var character *structs.Character
+	raid := profile.raid
+	if parts[0] == RESET_WIPE {
+		character = template
+		character.ID = current.ID
+		character.AID = current.AID
+		character.Savage = current.Savage
+		character.Info.Nickname = current.Info.Nickname
+		character.Info.LowerNickname = current.Info.LowerNickname
+		character.Info.Side = current.Info.Side
+		character.Info.Voice = current.Info.Voice
+		character.Info.RegistrationDate = time.Now().Unix()
+		character.Customization = current.Customization
+		raid = RaidProfileStruct{}
+		summary.Reset[RESET_WIPE] = fmt.Sprintf("level %d to %d", current.Info.Level, character.Info.Level)
+	} else {
+		character, err = cloneCharacter(current)
+		if err != nil {
+			return nil, fmt.Errorf("error copying character of profile %s: %w", profileID, err)
+		}
+		for _, part := range parts {
+			summary.Reset[part] = profileResets[part](character, template)
+		}
+	}
+
+	reset := &ProfileStruct{
+		account:   profile.account,
+		character: character,
+		storage:   profile.storage,
+		dialogues: profile.dialogues,
+		raid:      raid,
+		purchases: profile.purchases,
+	}
+	// held until saved, so requests waiting for the old profile see it complete
+	reset.lock.Lock()
+	defer reset.lock.Unlock()
+
+	profileWriteLock.Lock()
+	backup, err := backupProfile(profileID)
+	if err == nil {
+		profilesLock.Lock()
+		Database.profiles[profileID] = reset
+		profilesLock.Unlock()
+	}
+	profileWriteLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	summary.Backup = backup
+
+	markProfileDirty(reset, PROFILE_CHARACTER|PROFILE_RAID)
+	if err := saveProfile(profileID); err != nil {
+		return nil, err
+	}
+
+	log.Print(summary.String())
+	return summary, nil
+}
+
+// resetProfiles resets every profile that has a character. Profiles that
+// fail are reported in the error; the others are still reset.
+func resetProfiles(parts []string) ([]*ProfileResetSummary, error) {
+	if err := validateResetParts(parts); err != nil {
+		return nil, err
+	}
+
+	var profileIDs []string
+	for profileID, profile := range getProfiles() {
+		profile.lock.Lock()
+		if profile.character != nil {
+			profileIDs = append(profileIDs, profileID)
+		}
+		profile.lock.Unlock()
+	}
+	sort.Strings(profileIDs)
+
+	summaries := make([]*ProfileResetSummary, 0, len(profileIDs))
+	var failed []string
+	for _, profileID := range profileIDs {
+		summary, err := resetProfile(profileID, parts)
+		if err != nil {
+			log.Println(err)
+			failed = append(failed, profileID)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	if len(failed) != 0 {
+		return summaries, fmt.Errorf("error resetting profiles %v", failed)
+	}
+	return summaries, nil
+}
+
+// resetCommand resets one or every profile while the server is stopped:
+//
+//	MT-GO reset -parts stash,quests <profile id>
+//	MT-GO reset -all -parts wipe
+func resetCommand(args []string) error {
+	flags := flag.NewFlagSet("reset", flag.ContinueOnError)
+	all := flags.Bool("all", false, "reset every profile")
+	parts := flags.String("parts", "", "comma separated parts to reset: wipe, stash, quests, hideout, traders or skills")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *all == (flags.NArg() == 1) || flags.NArg() > 1 {
+		return fmt.Errorf("usage: reset -parts <parts> (-all | <profile id>)")
+	}
+
+	initializeDatabaseMaps(&Database)
+	if err := setServerConfigCore(&Database.core, tools.ReadFile); err != nil {
+		return err
+	}
+	if err := setEditions(&Database, tools.ReadFile); err != nil {
+		return err
+	}
+	if err := setProfiles(&Database); err != nil {
+		return err
+	}
+
+	resetParts := strings.Split(*parts, ",")
+	if *parts == "" {
+		resetParts = nil
+	}
+
+	if !*all {
+		_, err := resetProfile(flags.Arg(0), resetParts)
+		return err
+	}
+
+	summaries, err := resetProfiles(resetParts)
+	log.Printf("Reset %d profiles", len(summaries))
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackendErrorCode is the error code of a response envelope. The client reads
+// it as one of the backend's own error codes, not as an http status, although
+// some of them share their numbers.
+type BackendErrorCode int
+
+// Backend error codes the server responds with
+const (
+	BACKEND_NONE                BackendErrorCode = 0
+	BACKEND_UNKNOWN_ERROR       BackendErrorCode = 200
+	BACKEND_NOT_AUTHORIZED      BackendErrorCode = 201
+	BACKEND_NICKNAME_NOT_UNIQUE BackendErrorCode = 225
+	BACKEND_NICKNAME_NOT_VALID  BackendErrorCode = 226
+	BACKEND_BAD_REQUEST         BackendErrorCode = 400
+	BACKEND_FORBIDDEN           BackendErrorCode = 403
+	BACKEND_NOT_FOUND           BackendErrorCode = 404
+)
+
+// ResponseBody is the envelope every EFT client route expects
+type ResponseBody struct {
+	Err    BackendErrorCode `json:"err"`
+	Errmsg interface{}      `json:"errmsg"`
+	Data   interface{}      `json:"data"`
+}
+
+// applyBody wraps data in the standard client response envelope
+func applyBody(data interface{}) *ResponseBody {
+	return &ResponseBody{
+		Err:    BACKEND_NONE,
+		Errmsg: nil,
+		Data:   data,
+	}
+}
+
+// applyErrorBody wraps an error code and message in the client response envelope
+func applyErrorBody(code BackendErrorCode, message string) *ResponseBody {
+	return &ResponseBody{
+		Err:    code,
+		Errmsg: message,
+		Data:   nil,
+	}
+}
+
+// isUnityClient returns true if the request was sent by the game client
+func isUnityClient(c *gin.Context) bool {
+	return strings.Contains(c.Request.Header.Get("User-Agent"), "Unity")
+}
+
+// sendResponse wraps data in the client envelope and writes it to the response,
+// compressed for the game client and as plain JSON for everything else
+func sendResponse(c *gin.Context, data interface{}) {
+	sendBody(c, http.StatusOK, applyBody(data))
+}
+
+// sendError aborts the request with an error envelope. The client reads the
+// error from the envelope, so the http status stays 200.
+func sendError(c *gin.Context, code BackendErrorCode, message string) {
+	sendBody(c, http.StatusOK, applyErrorBody(code, message))
+	c.Abort()
+}
+
+// sendBody serializes body and writes it with the given status code
+func sendBody(c *gin.Context, status int, body interface{}) {
+	if !isUnityClient(c) {
+		c.JSON(status, body)
+		return
+	}
+
+	if err := sendZlibJSONReply(c, status, body); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// sendZlibJSONReply serializes body and writes it zlib-deflated. The client
+// inflates the payload itself, so no Content-Encoding header is set or the
+// transport would try to decompress it first.
+func sendZlibJSONReply(c *gin.Context, status int, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshal body: %w", err)
+	}
+
+	var buffer bytes.Buffer
+	writer := zlib.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("error zlib writer: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error zlib writer: %w", err)
+	}
+
+	c.Header("Content-Length", strconv.Itoa(buffer.Len()))
+	c.Data(status, "application/json; charset=utf-8", buffer.Bytes())
+	return nil
+}
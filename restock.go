@@ -0,0 +1,248 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DEFAULT_RESTOCK_INTERVAL is used for traders server.json sets no interval for
+const DEFAULT_RESTOCK_INTERVAL = time.Hour
+
+// RESTOCK_CHECK_INTERVAL is how often the scheduler looks for traders due to restock
+const RESTOCK_CHECK_INTERVAL = time.Second
+
+// TraderPurchases is what a player bought from a trader, per assort item,
+// during the restock that ends at Resupply
+type TraderPurchases struct {
+	Resupply int            `json:"resupply"`
+	Bought   map[string]int `json:"bought"`
+}
+
+// setPurchases reads the purchases of a profile, which are empty for profiles
+// that have not bought anything with a buy limit yet
+func setPurchases(read profileFileReader, profileID string) map[string]*TraderPurchases {
+	purchases := make(map[string]*TraderPurchases)
+	err := readProfileFileInto(read, profileID, PURCHASES_FILE, &purchases)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]*TraderPurchases)
+	} else if err != nil {
+		log.Printf("Error reading purchases.json for profile %s: %v", profileID, err)
+		return make(map[string]*TraderPurchases)
+	}
+	return purchases
+}
+
+// getBoughtCount returns how many of an assort item a profile bought from a
+// trader since it last restocked. Purchases of an earlier restock no longer
+// count, which is how personal buy limits reset.
+func getBoughtCount(profile *ProfileStruct, trader *TraderStruct, assortID string) int {
+	purchases, ok := profile.purchases[trader.base.ID]
+	if !ok || purchases.Resupply != trader.base.NextResupply {
+		return 0
+	}
+	return purchases.Bought[assortID]
+}
+
+// takeBuyLimit counts a purchase against the personal buy limit an assort
+// item has per restock, failing if the limit would be exceeded
+func takeBuyLimit(ctx *itemEventContext, trader *TraderStruct, item *structs.InventoryItem, count int) error {
+	if item.Upd == nil || item.Upd.BuyRestrictionMax <= 0 {
+		return nil
+	}
+
+	limit := int(item.Upd.BuyRestrictionMax)
+	if bought := getBoughtCount(ctx.profile, trader, item.ID); bought+count > limit {
+		return fmt.Errorf("%s can be bought %d times per restock of trader %s, %d left", item.ID, limit, trader.base.ID, limit-bought)
+	}
+
+	if ctx.profile.purchases == nil {
+		ctx.profile.purchases = make(map[string]*TraderPurchases)
+	}
+	purchases, ok := ctx.profile.purchases[trader.base.ID]
+	if !ok || purchases.Resupply != trader.base.NextResupply {
+		purchases = &TraderPurchases{Resupply: trader.base.NextResupply, Bought: make(map[string]int)}
+		ctx.profile.purchases[trader.base.ID] = purchases
+	}
+	purchases.Bought[item.ID] += count
+	ctx.dirty |= PROFILE_PURCHASES
+
+	ctx.rollback = append(ctx.rollback, func() {
+		purchases.Bought[item.ID] -= count
+		if purchases.Bought[item.ID] <= 0 {
+			delete(purchases.Bought, item.ID)
+		}
+	})
+	return nil
+}
+
+// getRestockInterval returns the restock interval of a trader from the
+// "traderRestock" settings of server.json: "traders" maps trader ids to their
+// own interval and "interval" applies to the rest, both in seconds. Callers
+// must hold databaseLock.
+func getRestockInterval(traderID string) time.Duration {
+	interval := DEFAULT_RESTOCK_INTERVAL
+	config, ok := Database.core.serverConfig["traderRestock"].(map[string]interface{})
+	if !ok {
+		return interval
+	}
+	if seconds, ok := config["interval"].(float64); ok && seconds >= 1 {
+		interval = time.Duration(seconds * float64(time.Second))
+	}
+	traders, _ := config["traders"].(map[string]interface{})
+	if seconds, ok := traders[traderID].(float64); ok && seconds >= 1 {
+		interval = time.Duration(seconds * float64(time.Second))
+	}
+	return interval
+}
+
+// getNextResupply returns the first restock after now, counting whole
+// intervals from a previous one. Restocks stay on the same schedule across
+// restarts and reloads of base.json, so purchases saved during a restock
+// still count after the server comes back within it.
+func getNextResupply(resupply int, interval time.Duration, now int64) int {
+	seconds := int64(interval / time.Second)
+	if int64(resupply) > now {
+		return resupply
+	}
+	return int(int64(resupply) + ((now-int64(resupply))/seconds+1)*seconds)
+}
+
+// alignTraderResupplies moves the nextResupply of traders, as read from their
+// base.json, to their next restock on the running schedule. Traders are
+// aligned before they are served, so purchases counted against the current
+// restock keep counting after a reload. Callers must hold databaseLock.
+func alignTraderResupplies(traders map[string]*TraderStruct, now int64) {
+	for traderID, trader := range traders {
+		trader.base.NextResupply = getNextResupply(trader.base.NextResupply, getRestockInterval(traderID), now)
+	}
+}
+
+// TRADER_STOCK_FILE_PATH keeps the stock traders sold since they last
+// restocked, so limited stock does not refill on restart
+const TRADER_STOCK_FILE_PATH string = USER_FILE_PATH + "/traderStock.json"
+
+// traderStockDirty is set when traderStockBought changed since it was saved.
+// It is guarded by traderStockLock.
+var traderStockDirty bool
+
+// setTraderStock aligns the restocks of the loaded traders and restores the
+// stock they sold during their current restock. Stock sold during an earlier
+// restock is dropped, as the trader restocked while the server was down.
+func setTraderStock() error {
+	databaseLock.Lock()
+	defer databaseLock.Unlock()
+
+	alignTraderResupplies(Database.traders, time.Now().Unix())
+	if !tools.FileExist(TRADER_STOCK_FILE_PATH) {
+		return nil
+	}
+
+	stock := make(map[string]*TraderPurchases)
+	if err := tools.ReadParsedInto(TRADER_STOCK_FILE_PATH, &stock); err != nil {
+		return fmt.Errorf("error reading trader stock: %w", err)
+	}
+
+	traderStockLock.Lock()
+	defer traderStockLock.Unlock()
+	for traderID, sold := range stock {
+		trader, ok := Database.traders[traderID]
+		if !ok || sold == nil || sold.Resupply != trader.base.NextResupply || len(sold.Bought) == 0 {
+			continue
+		}
+		traderStockBought[traderID] = sold.Bought
+	}
+	return nil
+}
+
+// saveTraderStock writes the stock traders sold since they last restocked,
+// if it changed since the last save
+func saveTraderStock() error {
+	databaseLock.RLock()
+	defer databaseLock.RUnlock()
+	traderStockLock.Lock()
+	defer traderStockLock.Unlock()
+
+	if !traderStockDirty {
+		return nil
+	}
+
+	stock := make(map[string]*TraderPurchases, len(traderStockBought))
+	for traderID, bought := range traderStockBought {
+		trader, ok := Database.traders[traderID]
+		if !ok || len(bought) == 0 {
+			continue
+		}
+		sold := &TraderPurchases{Resupply: trader.base.NextResupply, Bought: make(map[string]int, len(bought))}
+		for assortID, count := range bought {
+			sold.Bought[assortID] = count
+		}
+		stock[traderID] = sold
+	}
+
+	path := tools.GetAbsolutePathFrom(TRADER_STOCK_FILE_PATH)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error saving trader stock: %w", err)
+	}
+	if err := tools.WriteToFile(TRADER_STOCK_FILE_PATH, tools.Stringify(stock, false)); err != nil {
+		return fmt.Errorf("error saving trader stock: %w", err)
+	}
+	traderStockDirty = false
+	return nil
+}
+
+// restockTraders moves the nextResupply of traders whose restock time passed
+// to their next restock and clears the stock sold since. Traders are aligned
+// to their schedule when loaded, so a passed restock time is always a restock.
+func restockTraders(now int64) {
+	databaseLock.RLock()
+	due := false
+	for _, trader := range Database.traders {
+		due = due || int64(trader.base.NextResupply) <= now
+	}
+	databaseLock.RUnlock()
+	if !due {
+		return
+	}
+
+	databaseLock.Lock()
+	defer databaseLock.Unlock()
+
+	for traderID, trader := range Database.traders {
+		if int64(trader.base.NextResupply) > now {
+			continue
+		}
+		next := getNextResupply(trader.base.NextResupply, getRestockInterval(traderID), now)
+		trader.base.NextResupply = next
+
+		traderStockLock.Lock()
+		if _, ok := traderStockBought[traderID]; ok {
+			delete(traderStockBought, traderID)
+			traderStockDirty = true
+		}
+		traderStockLock.Unlock()
+		log.Printf("Trader %s restocked, next restock at %s", traderID, time.Unix(int64(next), 0).Format(time.DateTime))
+	}
+}
+
+// scheduleRestocks restocks traders on their intervals until stop is closed
+func scheduleRestocks(stop <-chan struct{}) {
+	ticker := time.NewTicker(RESTOCK_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	restockTraders(time.Now().Unix())
+	for {
+		select {
+		case <-ticker.C:
+			restockTraders(time.Now().Unix())
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"MT-GO/plugins"
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DEFAULT_AUTOSAVE_INTERVAL is used when server.json has no autosaveInterval
+const DEFAULT_AUTOSAVE_INTERVAL = 60 * time.Second
+
+// profileFile is a set of the files of a profile folder
+type profileFile uint8
+
+const (
+	PROFILE_ACCOUNT profileFile = 1 << iota
+	PROFILE_CHARACTER
+	PROFILE_STORAGE
+	PROFILE_DIALOGUES
+	PROFILE_RAID
+	PROFILE_PURCHASES
+
+	PROFILE_ALL = PROFILE_ACCOUNT | PROFILE_CHARACTER | PROFILE_STORAGE | PROFILE_DIALOGUES | PROFILE_RAID | PROFILE_PURCHASES
+)
+
+// profileFileNames maps each profile file to its name in the profile folder
+var profileFileNames = []struct {
+	file profileFile
+	name string
+}{
+	{PROFILE_ACCOUNT, ACCOUNT_FILE},
+	{PROFILE_CHARACTER, CHARACTER_FILE},
+	{PROFILE_STORAGE, STORAGE_FILE},
+	{PROFILE_DIALOGUES, DIALOGUES_FILE},
+	{PROFILE_RAID, RAID_FILE},
+	{PROFILE_PURCHASES, PURCHASES_FILE},
+}
+
+// dirtyLock guards the dirty files of every profile
+var dirtyLock sync.Mutex
+
+// markProfileDirty records that files of a profile changed and must be saved
+func markProfileDirty(profile *ProfileStruct, files profileFile) {
+	dirtyLock.Lock()
+	profile.dirty |= files
+	dirtyLock.Unlock()
+}
+
+// profileSave is the serialized content of the dirty files of a profile
+type profileSave struct {
+	profileID string
+	dirty     profileFile
+	files     map[string][]byte
+}
+
+// snapshotProfile serializes the dirty files of a profile and clears them. It
+// returns nil if nothing changed since the last save. Callers must hold the
+// profile lock.
+func snapshotProfile(profileID string, profile *ProfileStruct) *profileSave {
+	dirtyLock.Lock()
+	dirty := profile.dirty
+	profile.dirty = 0
+	dirtyLock.Unlock()
+
+	if dirty == 0 {
+		return nil
+	}
+
+	contents := map[profileFile]interface{}{
+		PROFILE_ACCOUNT:   profile.account,
+		PROFILE_CHARACTER: profile.character,
+		PROFILE_STORAGE:   profile.storage,
+		PROFILE_DIALOGUES: profile.dialogues,
+		PROFILE_RAID:      raidProfileToJSON(profile.raid),
+		PROFILE_PURCHASES: profile.purchases,
+	}
+
+	save := &profileSave{
+		profileID: profileID,
+		dirty:     dirty,
+		files:     make(map[string][]byte),
+	}
+	for _, file := range profileFileNames {
+		if dirty&file.file == 0 || contents[file.file] == nil {
+			continue
+		}
+		save.files[file.name] = []byte(tools.Stringify(contents[file.file], false))
+	}
+	return save
+}
+
+// writeProfileSave writes a snapshot to the profile folder and backs it up if
+// a backup is due. Files are marked dirty again if writing fails so the next
+// save retries them. Snapshots of a profile that was since replaced, such as
+// by restoring a backup, are dropped.
+func writeProfileSave(profile *ProfileStruct, save *profileSave, backups BackupConfigStruct) error {
+	profileWriteLock.Lock()
+	defer profileWriteLock.Unlock()
+
+	if current, ok := getProfile(save.profileID); !ok || current != profile {
+		return nil
+	}
+
+	store, err := getProfileStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Write(save.profileID, save.files); err != nil {
+		markProfileDirty(profile, save.dirty)
+		return fmt.Errorf("error writing profile %s: %w", save.profileID, err)
+	}
+
+	backupProfileIfDue(save.profileID, backups)
+
+	// mods get a copy of what was saved, as the profile is not locked here
+	if data, ok := save.files[CHARACTER_FILE]; ok {
+		character := &structs.Character{}
+		if err := json.Unmarshal(data, character); err != nil {
+			return fmt.Errorf("error decoding saved character of profile %s: %w", save.profileID, err)
+		}
+		plugins.ProfileSaved(save.profileID, character)
+	}
+	return nil
+}
+
+// saveProfile writes the dirty files of a profile now. Request handlers call
+// it, holding the profile lock, for changes that must not wait for the next
+// autosave.
+func saveProfile(profileID string) error {
+	profile, ok := getProfile(profileID)
+	if !ok {
+		return fmt.Errorf("unknown profile %s", profileID)
+	}
+
+	save := snapshotProfile(profileID, profile)
+	if save == nil {
+		return nil
+	}
+	return writeProfileSave(profile, save, getBackupConfig())
+}
+
+// saveDirtyProfiles writes every profile with unsaved changes. Each profile is
+// serialized with its lock held so the save is consistent, then written
+// without it so requests are not kept waiting on the store.
+func saveDirtyProfiles() error {
+	databaseLock.RLock()
+	backups := getBackupConfig()
+	databaseLock.RUnlock()
+
+	saves := make(map[*ProfileStruct]*profileSave)
+	for profileID, profile := range getProfiles() {
+		profile.lock.Lock()
+		if save := snapshotProfile(profileID, profile); save != nil {
+			saves[profile] = save
+		}
+		profile.lock.Unlock()
+	}
+
+	var failed []string
+	for profile, save := range saves {
+		if err := writeProfileSave(profile, save, backups); err != nil {
+			log.Println(err)
+			failed = append(failed, save.profileID)
+		}
+	}
+
+	if len(failed) != 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("error saving profiles %v", failed)
+	}
+	return nil
+}
+
+// getAutosaveInterval returns autosaveInterval from server.json, in seconds
+func getAutosaveInterval() time.Duration {
+	databaseLock.RLock()
+	defer databaseLock.RUnlock()
+
+	seconds, ok := Database.core.serverConfig["autosaveInterval"].(float64)
+	if !ok || seconds <= 0 {
+		return DEFAULT_AUTOSAVE_INTERVAL
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// autosaveProfiles saves dirty profiles, and the stock traders sold, on every
+// interval until stop is closed
+func autosaveProfiles(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := saveDirtyProfiles(); err != nil {
+				log.Printf("Autosave failed: %v", err)
+			}
+			if err := saveTraderStock(); err != nil {
+				log.Printf("Autosave failed: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
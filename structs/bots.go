@@ -0,0 +1,31 @@
+package structs
+
+// BotHealth is the health of a bot type for one difficulty
+type BotHealth struct {
+	BodyParts map[string]*BotBodyPart `json:"BodyParts"`
+}
+
+type BotBodyPart struct {
+	Health BotHealthValue `json:"Health"`
+}
+
+type BotHealthValue struct {
+	Current float64 `json:"Current"`
+	Maximum float64 `json:"Maximum"`
+}
+
+// BotLoadout is a bot type's loadout.json, template ids per equipment slot
+type BotLoadout struct {
+	Backpack        []string `json:"backpack"`
+	BodyArmor       []string `json:"bodyArmor"`
+	Earpiece        []string `json:"earpiece"`
+	Eyewear         []string `json:"eyewear"`
+	Facecover       []string `json:"facecover"`
+	Headwear        []string `json:"headwear"`
+	Holster         []string `json:"holster"`
+	Melee           []string `json:"melee"`
+	Pocket          []string `json:"pocket"`
+	PrimaryWeapon   []string `json:"primaryWeapon"`
+	SecondaryWeapon []string `json:"secondaryWeapon"`
+	Vest            []string `json:"vest"`
+}
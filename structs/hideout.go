@@ -0,0 +1,59 @@
+package structs
+
+// HideoutArea is an entry of hideout/areas.json
+type HideoutArea struct {
+	ID                     string                       `json:"_id"`
+	Type                   int                          `json:"type"`
+	Enabled                bool                         `json:"enabled"`
+	NeedsFuel              bool                         `json:"needsFuel"`
+	TakeFromSlotLocked     bool                         `json:"takeFromSlotLocked"`
+	CraftGivesExp          bool                         `json:"craftGivesExp"`
+	DisplayLevel           bool                         `json:"displayLevel"`
+	EnableAreaRequirements bool                         `json:"enableAreaRequirements"`
+	Requirements           []*HideoutRequirement        `json:"requirements"`
+	Stages                 map[string]*HideoutAreaStage `json:"stages"`
+}
+
+type HideoutAreaStage struct {
+	AutoUpgrade      bool                     `json:"autoUpgrade"`
+	Bonuses          []map[string]interface{} `json:"bonuses"`
+	ConstructionTime int                      `json:"constructionTime"`
+	Description      string                   `json:"description"`
+	DisplayInterface bool                     `json:"displayInterface"`
+	Improvements     []map[string]interface{} `json:"improvements"`
+	Requirements     []*HideoutRequirement    `json:"requirements"`
+	Slots            int                      `json:"slots"`
+}
+
+// HideoutRequirement is shared by area stages and productions; which fields
+// are set depends on its type
+type HideoutRequirement struct {
+	Type          string `json:"type"`
+	AreaType      *int   `json:"areaType,omitempty"`
+	RequiredLevel *int   `json:"requiredLevel,omitempty"`
+	TemplateID    string `json:"templateId,omitempty"`
+	Count         *int   `json:"count,omitempty"`
+	IsEncoded     *bool  `json:"isEncoded,omitempty"`
+	IsFunctional  *bool  `json:"isFunctional,omitempty"`
+	Resource      *int   `json:"resource,omitempty"`
+	QuestID       string `json:"questId,omitempty"`
+	TraderID      string `json:"traderId,omitempty"`
+	LoyaltyLevel  *int   `json:"loyaltyLevel,omitempty"`
+	SkillName     string `json:"skillName,omitempty"`
+	SkillLevel    *int   `json:"skillLevel,omitempty"`
+}
+
+// HideoutProduction is an entry of the data in hideout/productions.json
+type HideoutProduction struct {
+	ID                           string                `json:"_id"`
+	AreaType                     int                   `json:"areaType"`
+	Continuous                   bool                  `json:"continuous"`
+	Count                        int                   `json:"count"`
+	EndProduct                   string                `json:"endProduct"`
+	IsEncoded                    bool                  `json:"isEncoded"`
+	Locked                       bool                  `json:"locked"`
+	NeedFuelForAllProductionTime bool                  `json:"needFuelForAllProductionTime"`
+	ProductionLimitCount         int                   `json:"productionLimitCount"`
+	ProductionTime               int                   `json:"productionTime"`
+	Requirements                 []*HideoutRequirement `json:"requirements"`
+}
@@ -0,0 +1,114 @@
+package structs
+
+import (
+	"encoding/json"
+)
+
+// DatabaseItem is an item template from items.json. Templates are read-only,
+// so the original JSON is kept and served back to the client untouched.
+type DatabaseItem struct {
+	ID     string    `json:"_id"`
+	Name   string    `json:"_name"`
+	Parent string    `json:"_parent"`
+	Type   string    `json:"_type"`
+	Proto  string    `json:"_proto,omitempty"`
+	Props  ItemProps `json:"_props"`
+
+	raw json.RawMessage
+}
+
+func (i *DatabaseItem) UnmarshalJSON(data []byte) error {
+	type item DatabaseItem
+	if err := json.Unmarshal(data, (*item)(i)); err != nil {
+		return err
+	}
+	i.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (i DatabaseItem) MarshalJSON() ([]byte, error) {
+	if i.raw != nil {
+		return i.raw, nil
+	}
+	type item DatabaseItem
+	return json.Marshal(item(i))
+}
+
+// ItemProps holds the _props of an item template the server reads
+type ItemProps struct {
+	Name               string     `json:"Name"`
+	ShortName          string     `json:"ShortName"`
+	Description        string     `json:"Description"`
+	Weight             float64    `json:"Weight"`
+	Width              int        `json:"Width"`
+	Height             int        `json:"Height"`
+	StackMaxSize       int        `json:"StackMaxSize"`
+	ExtraSizeLeft      int        `json:"ExtraSizeLeft"`
+	ExtraSizeRight     int        `json:"ExtraSizeRight"`
+	ExtraSizeUp        int        `json:"ExtraSizeUp"`
+	ExtraSizeDown      int        `json:"ExtraSizeDown"`
+	ExtraSizeForceAdd  bool       `json:"ExtraSizeForceAdd"`
+	MergesWithChildren bool       `json:"MergesWithChildren"`
+	Foldable           bool       `json:"Foldable"`
+	FoldedSlot         string     `json:"FoldedSlot"`
+	SizeReduceRight    int        `json:"SizeReduceRight"`
+	QuestItem          bool       `json:"QuestItem"`
+	CanSellOnRagfair   bool       `json:"CanSellOnRagfair"`
+	CreditsPrice       float64    `json:"CreditsPrice"`
+	ExamineExperience  int        `json:"ExamineExperience"`
+	Grids              []ItemGrid `json:"Grids,omitempty"`
+	Slots              []ItemSlot `json:"Slots,omitempty"`
+	Chambers           []ItemSlot `json:"Chambers,omitempty"`
+	Cartridges         []ItemSlot `json:"Cartridges,omitempty"`
+}
+
+// ItemGrid is a container grid of an item template
+type ItemGrid struct {
+	ID     string         `json:"_id"`
+	Name   string         `json:"_name"`
+	Parent string         `json:"_parent"`
+	Proto  string         `json:"_proto"`
+	Props  ItemGridsProps `json:"_props"`
+}
+
+type ItemGridsProps struct {
+	Filters        []ItemFilter `json:"filters"`
+	CellsH         int          `json:"cellsH"`
+	CellsV         int          `json:"cellsV"`
+	MinCount       int          `json:"minCount"`
+	MaxCount       int          `json:"maxCount"`
+	MaxWeight      float64      `json:"maxWeight"`
+	IsSortingTable bool         `json:"isSortingTable"`
+}
+
+// ItemSlot is a mod slot, chamber or cartridge slot of an item template
+type ItemSlot struct {
+	ID                    string         `json:"_id"`
+	Name                  string         `json:"_name"`
+	Parent                string         `json:"_parent"`
+	Proto                 string         `json:"_proto"`
+	Props                 ItemSlotsProps `json:"_props"`
+	Required              bool           `json:"_required"`
+	MergeSlotWithChildren bool           `json:"_mergeSlotWithChildren"`
+	MaxCount              int            `json:"_max_count,omitempty"`
+}
+
+type ItemSlotsProps struct {
+	Filters []ItemFilter `json:"filters"`
+}
+
+type ItemFilter struct {
+	Filter         []string `json:"Filter"`
+	ExcludedFilter []string `json:"ExcludedFilter,omitempty"`
+}
+
+// ItemPreset is an entry of ItemPresets in globals.json
+type ItemPreset struct {
+	ID               string           `json:"_id"`
+	Type             string           `json:"_type"`
+	ChangeWeaponName bool             `json:"_changeWeaponName"`
+	Name             string           `json:"_name"`
+	Parent           string           `json:"_parent"`
+	Items            []*InventoryItem `json:"_items"`
+	Encyclopedia     string           `json:"_encyclopedia,omitempty"`
+}
@@ -0,0 +1,42 @@
+package structs
+
+import (
+	"encoding/json"
+)
+
+// LocationBase is a location's base.json. Only the fields the server reads
+// are typed; the original JSON is kept and served back to the client.
+type LocationBase struct {
+	ID                  string   `json:"Id"`
+	Name                string   `json:"Name"`
+	Enabled             bool     `json:"Enabled"`
+	Locked              bool     `json:"Locked"`
+	Insurance           bool     `json:"Insurance"`
+	IsSecret            bool     `json:"IsSecret"`
+	SafeLocation        bool     `json:"SafeLocation"`
+	EscapeTimeLimit     int      `json:"EscapeTimeLimit"`
+	MinPlayers          int      `json:"MinPlayers"`
+	MaxPlayers          int      `json:"MaxPlayers"`
+	RequiredPlayerLevel int      `json:"RequiredPlayerLevel"`
+	GlobalLootChance    float64  `json:"GlobalLootChanceModifier"`
+	AccessKeys          []string `json:"AccessKeys"`
+
+	raw json.RawMessage
+}
+
+func (b *LocationBase) UnmarshalJSON(data []byte) error {
+	type base LocationBase
+	if err := json.Unmarshal(data, (*base)(b)); err != nil {
+		return err
+	}
+	b.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (b LocationBase) MarshalJSON() ([]byte, error) {
+	if b.raw != nil {
+		return b.raw, nil
+	}
+	type base LocationBase
+	return json.Marshal(base(b))
+}
@@ -0,0 +1,39 @@
+package structs
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+var numberType = reflect.TypeOf(Number(0))
+
+// Number is a float that also accepts numeric strings, since parts of the
+// database store the same field as either "12" or 12.
+type Number float64
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		if text == "" {
+			*n = 0
+			return nil
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return &json.UnmarshalTypeError{Value: "string " + strconv.Quote(text), Type: numberType}
+		}
+		*n = Number(value)
+		return nil
+	}
+
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*n = Number(value)
+	return nil
+}
@@ -0,0 +1,338 @@
+package structs
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Account is a profile's account.json
+type Account struct {
+	ID                  string        `json:"id"`
+	Username            string        `json:"username"`
+	Password            string        `json:"password"`
+	Wipe                bool          `json:"wipe"`
+	Edition             string        `json:"edition"`
+	Lang                string        `json:"lang"`
+	TarkovPath          string        `json:"tarkovPath"`
+	Friends             []string      `json:"friends"`
+	FriendRequestInbox  []interface{} `json:"friendRequestInbox"`
+	FriendRequestOutbox []interface{} `json:"friendRequestOutbox"`
+	Matching            struct {
+		LookingForGroup bool `json:"LookingForGroup"`
+	} `json:"Matching"`
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+// Character is a profile's character.json, and the template it is created from
+type Character struct {
+	ID                string                   `json:"_id"`
+	AID               string                   `json:"aid"`
+	Savage            string                   `json:"savage"`
+	Info              CharacterInfo            `json:"Info"`
+	Customization     CharacterCustomization   `json:"Customization"`
+	Health            map[string]interface{}   `json:"Health"`
+	Inventory         CharacterInventory       `json:"Inventory"`
+	Skills            CharacterSkills          `json:"Skills"`
+	Stats             map[string]interface{}   `json:"Stats"`
+	Encyclopedia      map[string]bool          `json:"Encyclopedia"`
+	ConditionCounters map[string]interface{}   `json:"ConditionCounters"`
+	BackendCounters   map[string]interface{}   `json:"BackendCounters"`
+	InsuredItems      []interface{}            `json:"InsuredItems"`
+	Hideout           CharacterHideout         `json:"Hideout"`
+	Bonuses           []map[string]interface{} `json:"Bonuses"`
+	Notes             map[string]interface{}   `json:"Notes"`
+	Quests            []*CharacterQuest        `json:"Quests"`
+	TradersInfo       map[string]*TraderInfo   `json:"TradersInfo"`
+	RagfairInfo       map[string]interface{}   `json:"RagfairInfo"`
+	WishList          []string                 `json:"WishList"`
+	SurvivorClass     string                   `json:"SurvivorClass,omitempty"`
+	UnlockedInfo      map[string]interface{}   `json:"UnlockedInfo,omitempty"`
+}
+
+type CharacterInfo struct {
+	Nickname                string                 `json:"Nickname"`
+	LowerNickname           string                 `json:"LowerNickname"`
+	Side                    string                 `json:"Side"`
+	Voice                   string                 `json:"Voice"`
+	Level                   int                    `json:"Level"`
+	Experience              int                    `json:"Experience"`
+	RegistrationDate        int64                  `json:"RegistrationDate"`
+	GameVersion             string                 `json:"GameVersion"`
+	AccountType             int                    `json:"AccountType"`
+	MemberCategory          int                    `json:"MemberCategory"`
+	LockedMoveCommands      bool                   `json:"lockedMoveCommands"`
+	SavageLockTime          int64                  `json:"SavageLockTime"`
+	LastTimePlayedAsSavage  int64                  `json:"LastTimePlayedAsSavage"`
+	Settings                map[string]interface{} `json:"Settings"`
+	NicknameChangeDate      int64                  `json:"NicknameChangeDate"`
+	NeedWipeOptions         []interface{}          `json:"NeedWipeOptions"`
+	LastCompletedWipe       map[string]interface{} `json:"lastCompletedWipe"`
+	LastCompletedEvent      map[string]interface{} `json:"lastCompletedEvent"`
+	BannedState             bool                   `json:"BannedState"`
+	BannedUntil             int64                  `json:"BannedUntil"`
+	IsStreamerModeAvailable bool                   `json:"IsStreamerModeAvailable"`
+	Bans                    []interface{}          `json:"Bans"`
+}
+
+type CharacterCustomization struct {
+	Head  string `json:"Head"`
+	Body  string `json:"Body"`
+	Feet  string `json:"Feet"`
+	Hands string `json:"Hands"`
+}
+
+type CharacterInventory struct {
+	Items           []*InventoryItem       `json:"items"`
+	Equipment       string                 `json:"equipment"`
+	Stash           string                 `json:"stash"`
+	SortingTable    string                 `json:"sortingTable"`
+	QuestRaidItems  string                 `json:"questRaidItems"`
+	QuestStashItems string                 `json:"questStashItems"`
+	FastPanel       map[string]interface{} `json:"fastPanel"`
+}
+
+type CharacterSkills struct {
+	Common    []*CharacterSkill        `json:"Common"`
+	Mastering []map[string]interface{} `json:"Mastering"`
+	Points    int                      `json:"Points"`
+}
+
+type CharacterSkill struct {
+	ID                        string  `json:"Id"`
+	Progress                  float64 `json:"Progress"`
+	PointsEarnedDuringSession float64 `json:"PointsEarnedDuringSession"`
+	LastAccess                int64   `json:"LastAccess"`
+}
+
+type CharacterHideout struct {
+	Areas        []*CharacterHideoutArea `json:"Areas"`
+	Improvements map[string]interface{}  `json:"Improvements"`
+	Production   map[string]interface{}  `json:"Production"`
+}
+
+type CharacterHideoutArea struct {
+	Type                  int           `json:"type"`
+	Level                 int           `json:"level"`
+	Active                bool          `json:"active"`
+	PassiveBonusesEnabled bool          `json:"passiveBonusesEnabled"`
+	CompleteTime          int64         `json:"completeTime"`
+	Constructing          bool          `json:"constructing"`
+	Slots                 []interface{} `json:"slots"`
+	LastRecipe            string        `json:"lastRecipe"`
+}
+
+// CharacterQuest is the state of a quest on a character
+type CharacterQuest struct {
+	QID                 string           `json:"qid"`
+	StartTime           int64            `json:"startTime"`
+	Status              string           `json:"status"`
+	StatusTimers        map[string]int64 `json:"statusTimers"`
+	CompletedConditions []string         `json:"completedConditions,omitempty"`
+	AvailableAfter      int64            `json:"availableAfter,omitempty"`
+}
+
+// TraderInfo is the standing of a character with a trader
+type TraderInfo struct {
+	Disabled bool    `json:"disabled"`
+	SalesSum float64 `json:"salesSum"`
+	Standing float64 `json:"standing"`
+	Unlocked bool    `json:"unlocked"`
+}
+
+// InventoryItem is an item instance in a character's inventory or a trader's assort
+type InventoryItem struct {
+	ID       string        `json:"_id"`
+	Tpl      string        `json:"_tpl"`
+	ParentID string        `json:"parentId,omitempty"`
+	SlotID   string        `json:"slotId,omitempty"`
+	Location *ItemLocation `json:"location,omitempty"`
+	Upd      *ItemUpd      `json:"upd,omitempty"`
+}
+
+// ItemLocation is either a grid position or, for cartridges, an index
+type ItemLocation struct {
+	X          int
+	Y          int
+	R          int
+	IsSearched bool
+	Index      *int
+}
+
+type itemGridLocation struct {
+	X          int             `json:"x"`
+	Y          int             `json:"y"`
+	R          json.RawMessage `json:"r"`
+	IsSearched bool            `json:"isSearched"`
+}
+
+func (l *ItemLocation) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '{' {
+		var index int
+		if err := json.Unmarshal(data, &index); err != nil {
+			return err
+		}
+		*l = ItemLocation{Index: &index}
+		return nil
+	}
+
+	var location itemGridLocation
+	if err := json.Unmarshal(data, &location); err != nil {
+		return err
+	}
+	*l = ItemLocation{X: location.X, Y: location.Y, IsSearched: location.IsSearched}
+
+	if len(location.R) == 0 {
+		return nil
+	}
+	if location.R[0] != '"' {
+		return json.Unmarshal(location.R, &l.R)
+	}
+
+	var rotation string
+	if err := json.Unmarshal(location.R, &rotation); err != nil {
+		return err
+	}
+	switch rotation {
+	case "Horizontal", "":
+		l.R = 0
+	case "Vertical":
+		l.R = 1
+	default:
+		return &json.UnmarshalTypeError{Value: "string " + rotation, Type: reflect.TypeOf(0), Field: "r"}
+	}
+	return nil
+}
+
+func (l ItemLocation) MarshalJSON() ([]byte, error) {
+	if l.Index != nil {
+		return json.Marshal(*l.Index)
+	}
+	return json.Marshal(map[string]interface{}{
+		"x":          l.X,
+		"y":          l.Y,
+		"r":          l.R,
+		"isSearched": l.IsSearched,
+	})
+}
+
+// ItemUpd is the mutable state of an item instance
+type ItemUpd struct {
+	StackObjectsCount         int                    `json:"StackObjectsCount,omitempty"`
+	OriginalStackObjectsCount int                    `json:"OriginalStackObjectsCount,omitempty"`
+	SpawnedInSession          bool                   `json:"SpawnedInSession,omitempty"`
+	UnlimitedCount            bool                   `json:"UnlimitedCount,omitempty"`
+	BuyRestrictionMax         Number                 `json:"BuyRestrictionMax,omitempty"`
+	BuyRestrictionCurrent     int                    `json:"BuyRestrictionCurrent,omitempty"`
+	FireMode                  *UpdFireMode           `json:"FireMode,omitempty"`
+	Foldable                  *UpdFoldable           `json:"Foldable,omitempty"`
+	Togglable                 *UpdTogglable          `json:"Togglable,omitempty"`
+	Tag                       *UpdTag                `json:"Tag,omitempty"`
+	Repairable                *UpdRepairable         `json:"Repairable,omitempty"`
+	MedKit                    *UpdMedKit             `json:"MedKit,omitempty"`
+	FoodDrink                 *UpdFoodDrink          `json:"FoodDrink,omitempty"`
+	Resource                  *UpdResource           `json:"Resource,omitempty"`
+	RepairKit                 *UpdResource           `json:"RepairKit,omitempty"`
+	Key                       *UpdKey                `json:"Key,omitempty"`
+	Sight                     *UpdSight              `json:"Sight,omitempty"`
+	Light                     *UpdLight              `json:"Light,omitempty"`
+	FaceShield                *UpdFaceShield         `json:"FaceShield,omitempty"`
+	Lockable                  *UpdLockable           `json:"Lockable,omitempty"`
+	RecodableComponent        *UpdRecodable          `json:"RecodableComponent,omitempty"`
+	SideEffect                *UpdSideEffect         `json:"SideEffect,omitempty"`
+	Dogtag                    map[string]interface{} `json:"Dogtag,omitempty"`
+	Map                       map[string]interface{} `json:"Map,omitempty"`
+	Buff                      map[string]interface{} `json:"Buff,omitempty"`
+}
+
+// MarshalJSON keeps BuyRestrictionCurrent next to BuyRestrictionMax even when
+// nothing has been bought yet, which omitempty would otherwise drop
+func (u ItemUpd) MarshalJSON() ([]byte, error) {
+	type upd ItemUpd
+	if u.BuyRestrictionMax == 0 {
+		return json.Marshal(upd(u))
+	}
+	return json.Marshal(struct {
+		upd
+		BuyRestrictionCurrent int `json:"BuyRestrictionCurrent"`
+	}{upd(u), u.BuyRestrictionCurrent})
+}
+
+type UpdFireMode struct {
+	FireMode string `json:"FireMode"`
+}
+
+type UpdFoldable struct {
+	Folded bool `json:"Folded"`
+}
+
+type UpdTogglable struct {
+	On bool `json:"On"`
+}
+
+type UpdTag struct {
+	Name  string `json:"Name"`
+	Color int    `json:"Color"`
+}
+
+type UpdRepairable struct {
+	Durability    float64 `json:"Durability"`
+	MaxDurability float64 `json:"MaxDurability"`
+}
+
+type UpdMedKit struct {
+	HpResource float64 `json:"HpResource"`
+}
+
+type UpdFoodDrink struct {
+	HpPercent float64 `json:"HpPercent"`
+}
+
+type UpdResource struct {
+	Value float64 `json:"Value"`
+}
+
+type UpdKey struct {
+	NumberOfUsages int `json:"NumberOfUsages"`
+}
+
+type UpdSight struct {
+	ScopesCurrentCalibPointIndexes []int `json:"ScopesCurrentCalibPointIndexes"`
+	ScopesSelectedModes            []int `json:"ScopesSelectedModes"`
+	SelectedScope                  int   `json:"SelectedScope"`
+}
+
+type UpdLight struct {
+	IsActive     bool `json:"IsActive"`
+	SelectedMode int  `json:"SelectedMode"`
+}
+
+type UpdFaceShield struct {
+	Hits int `json:"Hits"`
+}
+
+type UpdLockable struct {
+	Locked bool `json:"Locked"`
+}
+
+type UpdRecodable struct {
+	IsEncoded bool `json:"IsEncoded"`
+}
+
+type UpdSideEffect struct {
+	Value float64 `json:"Value"`
+}
+
+// Storage is a profile's storage.json, the suits unlocked per side
+type Storage struct {
+	ID     string   `json:"_id"`
+	Suites []string `json:"suites"`
+}
+
+// EditionStorage is an edition's storage.json, the default suits per side
+type EditionStorage struct {
+	Bear []string `json:"bear"`
+	Usec []string `json:"usec"`
+}
+
+// Dialogues is a profile's dialogues.json, keyed by dialogue id
+type Dialogues map[string]map[string]interface{}
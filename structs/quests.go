@@ -0,0 +1,63 @@
+package structs
+
+// Quest is an entry of quests.json
+type Quest struct {
+	ID                         string                 `json:"_id"`
+	QuestName                  string                 `json:"QuestName,omitempty"`
+	AcceptPlayerMessage        string                 `json:"acceptPlayerMessage"`
+	CanShowNotificationsInGame bool                   `json:"canShowNotificationsInGame"`
+	ChangeQuestMessageText     string                 `json:"changeQuestMessageText"`
+	CompletePlayerMessage      string                 `json:"completePlayerMessage"`
+	Conditions                 QuestConditions        `json:"conditions"`
+	Description                string                 `json:"description"`
+	FailMessageText            string                 `json:"failMessageText"`
+	Image                      string                 `json:"image"`
+	InstantComplete            bool                   `json:"instantComplete"`
+	IsKey                      bool                   `json:"isKey"`
+	Location                   string                 `json:"location"`
+	Name                       string                 `json:"name"`
+	Note                       string                 `json:"note"`
+	QuestStatus                map[string]interface{} `json:"questStatus"`
+	Restartable                bool                   `json:"restartable"`
+	Rewards                    QuestRewards           `json:"rewards"`
+	SecretQuest                bool                   `json:"secretQuest"`
+	Side                       string                 `json:"side"`
+	StartedMessageText         string                 `json:"startedMessageText"`
+	SuccessMessageText         string                 `json:"successMessageText"`
+	TemplateID                 string                 `json:"templateId"`
+	TraderID                   string                 `json:"traderId"`
+	Type                       string                 `json:"type"`
+}
+
+type QuestConditions struct {
+	AvailableForStart  []*QuestCondition `json:"AvailableForStart"`
+	AvailableForFinish []*QuestCondition `json:"AvailableForFinish"`
+	Fail               []*QuestCondition `json:"Fail"`
+}
+
+// QuestCondition props vary with the condition type, so they stay untyped
+type QuestCondition struct {
+	Parent        string                 `json:"_parent"`
+	Props         map[string]interface{} `json:"_props"`
+	DynamicLocale bool                   `json:"dynamicLocale"`
+}
+
+type QuestRewards struct {
+	Started []*QuestReward `json:"Started"`
+	Success []*QuestReward `json:"Success"`
+	Fail    []*QuestReward `json:"Fail"`
+}
+
+// QuestReward value is a number or a string depending on the reward type
+type QuestReward struct {
+	ID           string           `json:"id"`
+	Index        int              `json:"index"`
+	Type         string           `json:"type"`
+	Target       string           `json:"target,omitempty"`
+	Value        interface{}      `json:"value,omitempty"`
+	Items        []*InventoryItem `json:"items,omitempty"`
+	FindInRaid   bool             `json:"findInRaid,omitempty"`
+	LoyaltyLevel int              `json:"loyaltyLevel,omitempty"`
+	TraderID     string           `json:"traderId,omitempty"`
+	Unknown      bool             `json:"unknown,omitempty"`
+}
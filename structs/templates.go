@@ -0,0 +1,23 @@
+package structs
+
+// HandbookItem is an entry of the handbook Items in templates.json
+type HandbookItem struct {
+	ID       string  `json:"Id"`
+	ParentID string  `json:"ParentId"`
+	Price    float64 `json:"Price"`
+}
+
+// HandbookCategory is an entry of the handbook Categories in templates.json
+type HandbookCategory struct {
+	ID       string `json:"Id"`
+	ParentID string `json:"ParentId"`
+	Icon     string `json:"Icon"`
+	Color    string `json:"Color"`
+	Order    string `json:"Order"`
+}
+
+// Handbook is the data of templates.json
+type Handbook struct {
+	Items      []*HandbookItem     `json:"Items"`
+	Categories []*HandbookCategory `json:"Categories"`
+}
@@ -0,0 +1,105 @@
+package structs
+
+// TraderBase is a trader's base.json
+type TraderBase struct {
+	ID                  string                `json:"_id"`
+	AvailableInRaid     bool                  `json:"availableInRaid"`
+	Avatar              string                `json:"avatar"`
+	BalanceDol          int                   `json:"balance_dol"`
+	BalanceEur          int                   `json:"balance_eur"`
+	BalanceRub          int                   `json:"balance_rub"`
+	BuyerUp             bool                  `json:"buyer_up"`
+	Currency            string                `json:"currency"`
+	CustomizationSeller bool                  `json:"customization_seller"`
+	Discount            int                   `json:"discount"`
+	DiscountEnd         int                   `json:"discount_end"`
+	GridHeight          int                   `json:"gridHeight"`
+	Insurance           TraderInsurance       `json:"insurance"`
+	ItemsBuy            *TraderItemsBuy       `json:"items_buy,omitempty"`
+	ItemsBuyProhibited  *TraderItemsBuy       `json:"items_buy_prohibited,omitempty"`
+	Location            string                `json:"location"`
+	LoyaltyLevels       []*TraderLoyaltyLevel `json:"loyaltyLevels"`
+	Medic               bool                  `json:"medic"`
+	Name                string                `json:"name"`
+	NextResupply        int                   `json:"nextResupply"`
+	Nickname            string                `json:"nickname"`
+	Repair              TraderRepair          `json:"repair"`
+	SellCategory        []string              `json:"sell_category"`
+	Surname             string                `json:"surname"`
+	UnlockedByDefault   bool                  `json:"unlockedByDefault"`
+}
+
+type TraderInsurance struct {
+	Availability     bool     `json:"availability"`
+	ExcludedCategory []string `json:"excluded_category"`
+	MaxReturnHour    int      `json:"max_return_hour"`
+	MaxStorageTime   int      `json:"max_storage_time"`
+	MinPayment       int      `json:"min_payment"`
+	MinReturnHour    int      `json:"min_return_hour"`
+}
+
+type TraderItemsBuy struct {
+	Category []string `json:"category"`
+	IDList   []string `json:"id_list"`
+}
+
+type TraderLoyaltyLevel struct {
+	BuyPriceCoef       float64 `json:"buy_price_coef"`
+	ExchangePriceCoef  float64 `json:"exchange_price_coef"`
+	HealPriceCoef      float64 `json:"heal_price_coef"`
+	InsurancePriceCoef Number  `json:"insurance_price_coef"`
+	MinLevel           int     `json:"minLevel"`
+	MinSalesSum        int     `json:"minSalesSum"`
+	MinStanding        float64 `json:"minStanding"`
+	RepairPriceCoef    float64 `json:"repair_price_coef"`
+}
+
+type TraderRepair struct {
+	Availability        bool     `json:"availability"`
+	Currency            string   `json:"currency"`
+	CurrencyCoefficient float64  `json:"currency_coefficient"`
+	ExcludedCategory    []string `json:"excluded_category"`
+	ExcludedIDList      []string `json:"excluded_id_list"`
+	PriceRate           float64  `json:"price_rate"`
+	Quality             Number   `json:"quality"`
+}
+
+// Assort is a trader's assort.json
+type Assort struct {
+	Items           []*InventoryItem           `json:"items"`
+	BarterScheme    map[string][][]*BarterItem `json:"barter_scheme"`
+	LoyalLevelItems map[string]int             `json:"loyal_level_items"`
+}
+
+// BarterItem is one requirement of a barter scheme
+type BarterItem struct {
+	Tpl            string  `json:"_tpl"`
+	Count          float64 `json:"count"`
+	Level          int     `json:"level,omitempty"`
+	OnlyFunctional bool    `json:"onlyFunctional,omitempty"`
+	Side           string  `json:"side,omitempty"`
+}
+
+// QuestAssort maps started, success and fail to the assort ids unlocked by each quest
+type QuestAssort map[string]map[string]string
+
+// TraderSuit is an entry of a trader's suits.json
+type TraderSuit struct {
+	ID           string                `json:"_id"`
+	IsActive     bool                  `json:"isActive"`
+	Requirements TraderSuitRequirement `json:"requirements"`
+	SuiteID      string                `json:"suiteId"`
+	TID          string                `json:"tid"`
+}
+
+type TraderSuitRequirement struct {
+	ItemRequirements  []map[string]interface{} `json:"itemRequirements"`
+	LoyaltyLevel      int                      `json:"loyaltyLevel"`
+	ProfileLevel      int                      `json:"profileLevel"`
+	QuestRequirements []string                 `json:"questRequirements"`
+	SkillRequirements []interface{}            `json:"skillRequirements"`
+	Standing          float64                  `json:"standing"`
+}
+
+// TraderDialogue is a trader's dialogue.json, message template ids per event
+type TraderDialogue map[string][]string
@@ -3,9 +3,11 @@ package tools
 import (
 	"bufio"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const (
@@ -21,28 +23,48 @@ const (
 	SS_FORMAT  string = "%s: %s"
 )
 
-// WriteToFile writes the given string of data to the specified file path
+// WriteToFile writes the given string of data to the specified file path.
+// The data is written to a temporary file that then replaces the target, so
+// a crash mid-write never leaves a truncated file behind.
 func WriteToFile(filePath string, data string) error {
 	path := GetAbsolutePathFrom(filePath)
-	file, err := os.Create(path)
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
-
-	defer file.Close()
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
 
 	writer := bufio.NewWriter(file)
-	_, err = writer.WriteString(data)
-	if err != nil {
+	if _, err := writer.WriteString(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
 		return err
 	}
 
-	err = writer.Flush()
-	if err != nil {
+	if err := os.Chmod(tempPath, 0644); err != nil {
 		return err
 	}
+	return os.Rename(tempPath, path)
+}
 
-	return nil
+// CopyFile copies the file at src to dst, replacing dst atomically
+func CopyFile(src string, dst string) error {
+	data, err := os.ReadFile(GetAbsolutePathFrom(src))
+	if err != nil {
+		return err
+	}
+	return WriteToFile(dst, string(data))
 }
 
 // GetAbsolutePathFrom returns the absolute path from a relative path
@@ -76,6 +98,10 @@ func FileExist(filePath string) bool {
 	return err == nil || !os.IsNotExist(err)
 }
 
+// FileReader reads the contents of a file. ReadFile reads it as it is on
+// disk; other readers may rewrite it, such as mods patching database files.
+type FileReader func(filePath string) ([]byte, error)
+
 // ReadFile reads the file at filePath and returns its contents as a byte slice.
 func ReadFile(filePath string) ([]byte, error) {
 	path := GetAbsolutePathFrom(filePath)
@@ -134,6 +160,57 @@ func GetFilesFrom(filePath string) ([]string, error) {
 	return files, nil
 }
 
+// GetDirectorySize returns the number of files and their total size under a
+// path, which may also be a single file
+func GetDirectorySize(filePath string) (int, int64) {
+	path := GetAbsolutePathFrom(filePath)
+
+	files, size := 0, int64(0)
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// GetLastModified returns the latest modification time of any file or
+// directory under a path
+func GetLastModified(filePath string) time.Time {
+	path := GetAbsolutePathFrom(filePath)
+
+	var latest time.Time
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest
+}
+
+// FormatBytes returns a human readable size
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func TransformInterfaceIntoMappedArray(data []interface{}) []map[string]interface{} {
 	results := make([]map[string]interface{}, 0, len(data))
 	for _, v := range data {
@@ -3,6 +3,8 @@ package tools
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -46,7 +48,12 @@ func ParseJSON(data *[]byte) (interface{}, error) {
 
 // ReadParsed reads a file path and parses it into an interface.
 func ReadParsed(filePath string) (interface{}, error) {
-	data, err := ReadFile(filePath)
+	return FileReader(ReadFile).Parsed(filePath)
+}
+
+// Parsed reads a file path with read and parses it into an interface.
+func (read FileReader) Parsed(filePath string) (interface{}, error) {
+	data, err := read(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -58,3 +65,152 @@ func ReadParsed(filePath string) (interface{}, error) {
 
 	return result, nil
 }
+
+// DecodeError reports the file and JSON path where decoding failed.
+type DecodeError struct {
+	File string
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf(SS_FORMAT, e.File, e.Err.Error())
+	}
+	return fmt.Sprintf("%s: %s: %s", e.File, e.Path, e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ReadParsedInto reads a file path and decodes it into v, reporting the JSON
+// path of any value that does not match the shape of v.
+func ReadParsedInto(filePath string, v interface{}) error {
+	return FileReader(ReadFile).ParsedInto(filePath, v)
+}
+
+// ParsedInto reads a file path with read and decodes it into v, like ReadParsedInto.
+func (read FileReader) ParsedInto(filePath string, v interface{}) error {
+	data, err := read(filePath)
+	if err != nil {
+		return err
+	}
+	return UnmarshalInto(filePath, "", data, v)
+}
+
+// ReadParsedMap reads a file path holding an object and decodes every value
+// into T, so a mismatch is reported with the key it was found under.
+func ReadParsedMap[T any](filePath string) (map[string]*T, error) {
+	return ReadParsedMapWith[T](ReadFile, filePath)
+}
+
+// ReadParsedMapWith reads a file path with read and decodes it like ReadParsedMap.
+func ReadParsedMapWith[T any](read FileReader, filePath string) (map[string]*T, error) {
+	data, err := read(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]json.RawMessage)
+	if err := UnmarshalInto(filePath, "", data, &entries); err != nil {
+		return nil, err
+	}
+
+	output := make(map[string]*T, len(entries))
+	for key, entry := range entries {
+		value := new(T)
+		if err := UnmarshalInto(filePath, key, entry, value); err != nil {
+			return nil, err
+		}
+		output[key] = value
+	}
+	return output, nil
+}
+
+// ReadParsedSlice reads a file path holding an array and decodes every
+// element into T, so a mismatch is reported with its index.
+func ReadParsedSlice[T any](filePath string) ([]*T, error) {
+	return ReadParsedSliceWith[T](ReadFile, filePath)
+}
+
+// ReadParsedSliceWith reads a file path with read and decodes it like ReadParsedSlice.
+func ReadParsedSliceWith[T any](read FileReader, filePath string) ([]*T, error) {
+	data, err := read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalSlice[T](filePath, "", data)
+}
+
+// UnmarshalSlice decodes every element of a JSON array into T, reporting
+// mismatches under path with the element index appended.
+func UnmarshalSlice[T any](file string, path string, data []byte) ([]*T, error) {
+	var entries []json.RawMessage
+	if err := UnmarshalInto(file, path, data, &entries); err != nil {
+		return nil, err
+	}
+
+	output := make([]*T, 0, len(entries))
+	for i, entry := range entries {
+		value := new(T)
+		if err := UnmarshalInto(file, path+"["+strconv.Itoa(i)+"]", entry, value); err != nil {
+			return nil, err
+		}
+		output = append(output, value)
+	}
+	return output, nil
+}
+
+// UnmarshalInto decodes data into v and wraps any failure in a DecodeError
+// carrying the file, path and the location of the mismatch inside data.
+func UnmarshalInto(file string, path string, data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return &DecodeError{
+			File: file,
+			Path: joinJSONPath(path, typeErr.Field),
+			Err:  fmt.Errorf("cannot decode %s into %s", typeErr.Value, typeErr.Type),
+		}
+	case errors.As(err, &syntaxErr):
+		line, column := offsetToLine(data, syntaxErr.Offset)
+		return &DecodeError{
+			File: file,
+			Path: path,
+			Err:  fmt.Errorf("line %d column %d: %w", line, column, err),
+		}
+	default:
+		return &DecodeError{File: file, Path: path, Err: err}
+	}
+}
+
+func joinJSONPath(parent string, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "":
+		return parent
+	default:
+		return parent + "." + child
+	}
+}
+
+func offsetToLine(data []byte, offset int64) (int, int) {
+	line, column := 1, 1
+	for i := int64(0); i < offset && i < int64(len(data)); i++ {
+		if data[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is one operation of a JSON Patch (RFC 6902). Only add,
+// remove and replace are supported.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to target: objects are
+// merged recursively, null removes a key and anything else replaces it.
+func MergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{}, len(patchObject))
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = MergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// MergePatchPaths returns the JSON pointers of every value a merge patch sets
+func MergePatchPaths(patch interface{}) []string {
+	paths := []string{}
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		object, ok := value.(map[string]interface{})
+		if !ok || len(object) == 0 {
+			paths = append(paths, prefix)
+			return
+		}
+		for key, child := range object {
+			walk(prefix+"/"+escapePointer(key), child)
+		}
+	}
+	walk("", patch)
+	return paths
+}
+
+// ApplyPatch applies JSON Patch operations to doc in order
+func ApplyPatch(doc interface{}, operations []PatchOperation) (interface{}, error) {
+	for i, operation := range operations {
+		tokens, err := parsePointer(operation.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		switch operation.Op {
+		case "add", "replace", "remove":
+			doc, err = patchValue(doc, tokens, operation)
+		default:
+			err = fmt.Errorf("unsupported op %q", operation.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d on %s: %w", i, operation.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func patchValue(node interface{}, tokens []string, operation PatchOperation) (interface{}, error) {
+	if len(tokens) == 0 {
+		if operation.Op == "remove" {
+			return nil, nil
+		}
+		return operation.Value, nil
+	}
+
+	token, last := tokens[0], len(tokens) == 1
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		child, exists := typed[token]
+		if !last {
+			if !exists {
+				return nil, fmt.Errorf("path not found at %q", token)
+			}
+			value, err := patchValue(child, tokens[1:], operation)
+			if err != nil {
+				return nil, err
+			}
+			typed[token] = value
+			return typed, nil
+		}
+
+		switch operation.Op {
+		case "add":
+			typed[token] = operation.Value
+		case "replace":
+			if !exists {
+				return nil, fmt.Errorf("path not found at %q", token)
+			}
+			typed[token] = operation.Value
+		case "remove":
+			if !exists {
+				return nil, fmt.Errorf("path not found at %q", token)
+			}
+			delete(typed, token)
+		}
+		return typed, nil
+
+	case []interface{}:
+		if last && operation.Op == "add" && token == "-" {
+			return append(typed, operation.Value), nil
+		}
+
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index > len(typed) || (index == len(typed) && !(last && operation.Op == "add")) {
+			return nil, fmt.Errorf("invalid array index %q", token)
+		}
+
+		if !last {
+			value, err := patchValue(typed[index], tokens[1:], operation)
+			if err != nil {
+				return nil, err
+			}
+			typed[index] = value
+			return typed, nil
+		}
+
+		switch operation.Op {
+		case "add":
+			typed = append(typed, nil)
+			copy(typed[index+1:], typed[index:])
+			typed[index] = operation.Value
+		case "replace":
+			typed[index] = operation.Value
+		case "remove":
+			typed = append(typed[:index], typed[index+1:]...)
+		}
+		return typed, nil
+
+	default:
+		return nil, fmt.Errorf("cannot descend into %q", token)
+	}
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
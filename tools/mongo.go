@@ -12,3 +12,17 @@ func GenerateMongoId() string {
 	}
 	return id()
 }
+
+// IsMongoId reports whether id has the form GenerateMongoId returns, 24
+// lowercase hex characters
+func IsMongoId(id string) bool {
+	if len(id) != 24 {
+		return false
+	}
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
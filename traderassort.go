@@ -0,0 +1,136 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Statuses of a character quest that unlock quest assort items
+const (
+	QUEST_STARTED              string = "Started"
+	QUEST_AVAILABLE_FOR_FINISH string = "AvailableForFinish"
+	QUEST_SUCCESS              string = "Success"
+	QUEST_FAIL                 string = "Fail"
+)
+
+// questAssortStatuses maps each list of questassort.json to the quest
+// statuses that unlock its items
+var questAssortStatuses = map[string][]string{
+	"started": {QUEST_STARTED, QUEST_AVAILABLE_FOR_FINISH, QUEST_SUCCESS},
+	"success": {QUEST_SUCCESS},
+	"fail":    {QUEST_FAIL},
+}
+
+// getQuestLockedAssort returns the assort items of a trader that are tied to
+// a quest and not unlocked by the quest status of a character
+func getQuestLockedAssort(character *structs.Character, questAssort structs.QuestAssort) map[string]bool {
+	statuses := make(map[string]string, len(character.Quests))
+	for _, quest := range character.Quests {
+		statuses[quest.QID] = quest.Status
+	}
+
+	listed := make(map[string]bool)
+	unlocked := make(map[string]bool)
+	for list, items := range questAssort {
+		for assortID, questID := range items {
+			listed[assortID] = true
+			for _, status := range questAssortStatuses[list] {
+				if statuses[questID] == status {
+					unlocked[assortID] = true
+				}
+			}
+		}
+	}
+
+	locked := make(map[string]bool)
+	for assortID := range listed {
+		if !unlocked[assortID] {
+			locked[assortID] = true
+		}
+	}
+	return locked
+}
+
+// checkAssortUnlocked reports why a character cannot buy an assort item of a
+// trader, hidden from them by getTraderAssort, or nil if they can
+func checkAssortUnlocked(character *structs.Character, trader *TraderStruct, assortID string) error {
+	if level := trader.baseAssort.LoyalLevelItems[assortID]; getLoyaltyLevel(character, trader) < level {
+		return fmt.Errorf("trader %s sells %s from loyalty level %d", trader.base.ID, assortID, level)
+	}
+	if getQuestLockedAssort(character, trader.questAssort)[assortID] {
+		return fmt.Errorf("%s of trader %s is locked by a quest", assortID, trader.base.ID)
+	}
+	return nil
+}
+
+// getTraderAssort generates the assort of a trader as a player sees it: the
+// items of baseAssort their loyalty level and quests unlock, with the stock
+// left and what they bought against buy limits since the trader last restocked
+func getTraderAssort(profile *ProfileStruct, trader *TraderStruct) *structs.Assort {
+	character := profile.character
+	level := getLoyaltyLevel(character, trader)
+	locked := getQuestLockedAssort(character, trader.questAssort)
+
+	base := trader.baseAssort
+	assort := &structs.Assort{
+		Items:           []*structs.InventoryItem{},
+		BarterScheme:    make(map[string][][]*structs.BarterItem),
+		LoyalLevelItems: make(map[string]int),
+	}
+
+	children := getAssortChildren(base)
+	for _, item := range children["hideout"] {
+		if base.LoyalLevelItems[item.ID] > level || locked[item.ID] {
+			continue
+		}
+		if _, ok := base.BarterScheme[item.ID]; !ok {
+			continue
+		}
+
+		if item.Upd != nil {
+			clone := *item
+			upd := *item.Upd
+			if left, limited := getTraderStock(trader.base.ID, item); limited {
+				if left < 0 {
+					left = 0
+				}
+				upd.StackObjectsCount = left
+			}
+			if upd.BuyRestrictionMax > 0 {
+				upd.BuyRestrictionCurrent = getBoughtCount(profile, trader, item.ID)
+			}
+			clone.Upd = &upd
+			item = &clone
+		}
+		// children of hidden items are never reached, wherever they are listed
+		subtree := getAssortSubtree(children, item)
+		assort.Items = append(assort.Items, subtree...)
+		assort.BarterScheme[item.ID] = base.BarterScheme[item.ID]
+		assort.LoyalLevelItems[item.ID] = base.LoyalLevelItems[item.ID]
+	}
+	return assort
+}
+
+func mainTraderAssort(c *gin.Context) {
+	profile, ok := getSessionProfile(c)
+	if !ok || profile.character == nil {
+		sendError(c, BACKEND_NOT_AUTHORIZED, "unknown session")
+		return
+	}
+	trader, ok := Database.traders[c.Param("traderId")]
+	if !ok {
+		sendError(c, BACKEND_NOT_FOUND, "unknown trader "+c.Param("traderId"))
+		return
+	}
+
+	assort := getTraderAssort(profile, trader)
+	data := map[string]interface{}{
+		"nextResupply":      trader.base.NextResupply,
+		"items":             assort.Items,
+		"barter_scheme":     assort.BarterScheme,
+		"loyal_level_items": assort.LoyalLevelItems,
+	}
+	sendResponse(c, data)
+}
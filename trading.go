@@ -0,0 +1,533 @@
+package main
+
+import (
+	"MT-GO/inventory"
+	"MT-GO/plugins"
+	"MT-GO/structs"
+	"MT-GO/tools"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Types of TradingConfirm actions
+const (
+	TRADE_BUY  string = "buy_from_trader"
+	TRADE_SELL string = "sell_to_trader"
+)
+
+// Templates of the currencies traders deal in
+const (
+	ROUBLES_TPL string = "5449016a4bdc2d6f028b456f"
+	DOLLARS_TPL string = "5696686a4bdc2da3298b456a"
+	EUROS_TPL   string = "569668774bdc2da2298b4568"
+)
+
+// currencyTpls maps the currency of a trader's base.json to its template
+var currencyTpls = map[string]string{
+	"RUB": ROUBLES_TPL,
+	"USD": DOLLARS_TPL,
+	"EUR": EUROS_TPL,
+}
+
+// TradingConfirmAction buys an assort item from a trader, paying with the
+// scheme items, or sells inventory items to a trader
+type TradingConfirmAction struct {
+	Type        string            `json:"type"`
+	TraderID    string            `json:"tid"`
+	ItemID      string            `json:"item_id"`
+	Count       int               `json:"count"`
+	SchemeID    int               `json:"scheme_id"`
+	SchemeItems []TradeSchemeItem `json:"scheme_items"`
+	Items       []TradeSchemeItem `json:"items"`
+}
+
+// TradeSchemeItem is an inventory item, and how many of its stack, paid or sold
+type TradeSchemeItem struct {
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+}
+
+func itemEventTradingConfirm(ctx *itemEventContext, data json.RawMessage) error {
+	action, err := decodeItemEvent[TradingConfirmAction](data)
+	if err != nil {
+		return err
+	}
+	trader, ok := Database.traders[action.TraderID]
+	if !ok {
+		return fmt.Errorf("unknown trader %s", action.TraderID)
+	}
+
+	switch action.Type {
+	case TRADE_BUY:
+		return buyFromTrader(ctx, trader, action)
+	case TRADE_SELL:
+		return sellToTrader(ctx, trader, action)
+	default:
+		return fmt.Errorf("unknown trade type %s", action.Type)
+	}
+}
+
+// traderStockLock guards traderStockBought
+var traderStockLock sync.Mutex
+
+// traderStockBought counts, per trader and assort item, how many every player
+// together bought since the trader last restocked. It is saved to
+// TRADER_STOCK_FILE_PATH by saveTraderStock.
+var traderStockBought = make(map[string]map[string]int)
+
+// getTraderStock returns how many of an assort item a trader has left, and
+// false if its stock is unlimited
+func getTraderStock(traderID string, item *structs.InventoryItem) (int, bool) {
+	if item.Upd == nil || item.Upd.UnlimitedCount {
+		return 0, false
+	}
+
+	traderStockLock.Lock()
+	defer traderStockLock.Unlock()
+	return item.Upd.StackObjectsCount - traderStockBought[traderID][item.ID], true
+}
+
+// takeTraderStock takes count of an assort item from the stock of a trader,
+// failing if it has fewer left
+func takeTraderStock(traderID string, item *structs.InventoryItem, count int) error {
+	if item.Upd == nil || item.Upd.UnlimitedCount {
+		return nil
+	}
+
+	traderStockLock.Lock()
+	defer traderStockLock.Unlock()
+
+	bought := traderStockBought[traderID]
+	if left := item.Upd.StackObjectsCount - bought[item.ID]; count > left {
+		return fmt.Errorf("trader %s has %d of %s left", traderID, left, item.ID)
+	}
+	if bought == nil {
+		bought = make(map[string]int)
+		traderStockBought[traderID] = bought
+	}
+	bought[item.ID] += count
+	traderStockDirty = true
+	return nil
+}
+
+// returnTraderStock puts back stock taken for a purchase that was rolled back
+func returnTraderStock(traderID string, item *structs.InventoryItem, count int) {
+	traderStockLock.Lock()
+	defer traderStockLock.Unlock()
+
+	if bought, ok := traderStockBought[traderID]; ok {
+		bought[item.ID] -= count
+		if bought[item.ID] <= 0 {
+			delete(bought, item.ID)
+		}
+		traderStockDirty = true
+	}
+}
+
+// getLoyaltyLevel returns the highest loyalty level of a trader whose level,
+// sales sum and standing requirements a character meets, starting at 1
+func getLoyaltyLevel(character *structs.Character, trader *TraderStruct) int {
+	info := character.TradersInfo[trader.base.ID]
+	if info == nil {
+		info = &structs.TraderInfo{}
+	}
+
+	level := 1
+	for i, loyalty := range trader.base.LoyaltyLevels {
+		if character.Info.Level >= loyalty.MinLevel && info.SalesSum >= float64(loyalty.MinSalesSum) && info.Standing >= loyalty.MinStanding {
+			level = i + 1
+		}
+	}
+	return level
+}
+
+// getTraderInfo returns the standing of a character with a trader, adding it
+// if the character never traded with them
+func getTraderInfo(character *structs.Character, traderID string) *structs.TraderInfo {
+	if character.TradersInfo == nil {
+		character.TradersInfo = make(map[string]*structs.TraderInfo)
+	}
+	info, ok := character.TradersInfo[traderID]
+	if !ok || info == nil {
+		info = &structs.TraderInfo{Unlocked: true}
+		character.TradersInfo[traderID] = info
+	}
+	return info
+}
+
+// getHandbookPrice returns the price in roubles traders value an item template at
+func getHandbookPrice(tpl string) float64 {
+	return Database.templates.TplLookup.Items.handbook[tpl]
+}
+
+// toTraderCurrency converts roubles to the currency a trader deals in
+func toTraderCurrency(trader *TraderStruct, roubles float64) float64 {
+	tpl, ok := currencyTpls[trader.base.Currency]
+	if !ok || tpl == ROUBLES_TPL {
+		return roubles
+	}
+	if rate := getHandbookPrice(tpl); rate > 0 {
+		return roubles / rate
+	}
+	return roubles
+}
+
+// getAssortItems returns an assort item of a trader followed by its children,
+// parents before their children whatever their order in the assort
+func getAssortItems(assort *structs.Assort, assortID string) []*structs.InventoryItem {
+	for _, item := range assort.Items {
+		if item.ID == assortID {
+			return getAssortSubtree(getAssortChildren(assort), item)
+		}
+	}
+	return nil
+}
+
+// getAssortChildren indexes the items of an assort by parent id
+func getAssortChildren(assort *structs.Assort) map[string][]*structs.InventoryItem {
+	children := make(map[string][]*structs.InventoryItem)
+	for _, item := range assort.Items {
+		children[item.ParentID] = append(children[item.ParentID], item)
+	}
+	return children
+}
+
+// getAssortSubtree returns item followed by everything attached to it, at
+// any depth, parents before their children. Items whose id is repeated are
+// only walked once, so a malformed assort cannot loop.
+func getAssortSubtree(children map[string][]*structs.InventoryItem, item *structs.InventoryItem) []*structs.InventoryItem {
+	items := []*structs.InventoryItem{item}
+	walked := map[string]bool{item.ID: true}
+	for i := 0; i < len(items); i++ {
+		for _, child := range children[items[i].ID] {
+			if !walked[child.ID] {
+				walked[child.ID] = true
+				items = append(items, child)
+			}
+		}
+	}
+	return items
+}
+
+// cloneItems copies an item and its children with fresh ids, keeping the
+// links between them
+func cloneItems(items []*structs.InventoryItem) []*structs.InventoryItem {
+	ids := make(map[string]string, len(items))
+	for _, item := range items {
+		ids[item.ID] = tools.GenerateMongoId()
+	}
+
+	clones := make([]*structs.InventoryItem, 0, len(items))
+	for _, item := range items {
+		clone := *item
+		clone.ID = ids[item.ID]
+		if parentID, ok := ids[item.ParentID]; ok {
+			clone.ParentID = parentID
+		}
+		if item.Location != nil {
+			location := *item.Location
+			clone.Location = &location
+		}
+		clone.Upd = cloneUpd(item.Upd)
+		clones = append(clones, &clone)
+	}
+	return clones
+}
+
+// cloneUpd returns a deep copy of the upd of an item, so changing the state
+// of a bought item, such as folding it, never changes the assort item
+func cloneUpd(upd *structs.ItemUpd) *structs.ItemUpd {
+	if upd == nil {
+		return nil
+	}
+	clone := &structs.ItemUpd{}
+	data, err := json.Marshal(upd)
+	if err == nil {
+		err = json.Unmarshal(data, clone)
+	}
+	if err != nil {
+		// upd only holds decoded JSON, so this is never reached in practice
+		shallow := *upd
+		return &shallow
+	}
+	return clone
+}
+
+// getBoughtUpd returns the upd of an item bought from an assort item, without
+// the stock and buy limits of the assort, which are not part of the item
+func getBoughtUpd(assortUpd *structs.ItemUpd, count int) *structs.ItemUpd {
+	upd := cloneUpd(assortUpd)
+	if upd == nil {
+		upd = &structs.ItemUpd{}
+	}
+	upd.StackObjectsCount = count
+	upd.UnlimitedCount = false
+	upd.BuyRestrictionMax = 0
+	upd.BuyRestrictionCurrent = 0
+	return upd
+}
+
+// addToStash puts an item, with its children after it, in the first free
+// place of the stash grid
+func addToStash(ctx *itemEventContext, items []*structs.InventoryItem) error {
+	stashID := ctx.character.Inventory.Stash
+	stash, err := ctx.inventory.Template(stashID)
+	if err != nil {
+		return err
+	}
+	if len(stash.Props.Grids) == 0 {
+		return fmt.Errorf("stash %s has no grid", stashID)
+	}
+	grid := stash.Props.Grids[0].Name
+
+	root := items[0]
+	root.ParentID = stashID
+	root.SlotID = grid
+	root.Location = &structs.ItemLocation{}
+	if err := ctx.inventory.Add(items...); err != nil {
+		return err
+	}
+
+	location, err := ctx.inventory.FindSpace(root.ID, stashID, grid)
+	if err != nil {
+		return fmt.Errorf("not enough space in stash")
+	}
+	root.Location = &location
+	return ctx.inventory.CanPlace(root.ID, stashID, grid, location)
+}
+
+// addStacksToStash adds count of an item template to the stash, split into
+// stacks no larger than it allows. Each stack gets a copy of upd, if any.
+func addStacksToStash(ctx *itemEventContext, tpl string, upd *structs.ItemUpd, count int) error {
+	template, ok := Database.items[tpl]
+	if !ok {
+		return fmt.Errorf("unknown item template %s", tpl)
+	}
+	stackMaxSize := template.Props.StackMaxSize
+	if stackMaxSize < 1 {
+		stackMaxSize = 1
+	}
+
+	for count > 0 {
+		size := count
+		if size > stackMaxSize {
+			size = stackMaxSize
+		}
+		item := &structs.InventoryItem{ID: tools.GenerateMongoId(), Tpl: tpl, Upd: getBoughtUpd(upd, size)}
+		if err := addToStash(ctx, []*structs.InventoryItem{item}); err != nil {
+			return err
+		}
+		count -= size
+	}
+	return nil
+}
+
+// takeItems removes count from the stack of an inventory item, or the whole
+// item with its children if that is all of it
+func takeItems(ctx *itemEventContext, id string, count int) error {
+	if err := checkMovable(ctx, id); err != nil {
+		return err
+	}
+	item, _ := ctx.inventory.Item(id)
+
+	stack := getStackCount(item)
+	if count < 1 || count > stack {
+		return fmt.Errorf("cannot take %d from a stack of %d", count, stack)
+	}
+	if count == stack {
+		unbindItems(ctx.character, ctx.inventory.Remove(id))
+		return nil
+	}
+	setStackCount(item, stack-count)
+	return nil
+}
+
+// payBarter takes the requirements of a barter scheme, count times over,
+// from the offered inventory items and returns the value of the currency paid
+// in roubles
+func payBarter(ctx *itemEventContext, scheme []*structs.BarterItem, count int, offered []TradeSchemeItem) (float64, error) {
+	required := make(map[string]int, len(scheme))
+	for _, requirement := range scheme {
+		required[requirement.Tpl] += int(math.Ceil(requirement.Count * float64(count)))
+	}
+
+	paid := 0.0
+	for _, offer := range offered {
+		item, ok := ctx.inventory.Item(offer.ID)
+		if !ok {
+			return 0, fmt.Errorf("unknown item %s", offer.ID)
+		}
+		tpl := item.Tpl
+		take := offer.Count
+		if take > required[tpl] {
+			take = required[tpl]
+		}
+		if take < 1 {
+			continue
+		}
+
+		if err := takeItems(ctx, offer.ID, take); err != nil {
+			return 0, err
+		}
+		required[tpl] -= take
+		for _, currency := range currencyTpls {
+			if tpl == currency {
+				paid += float64(take) * getHandbookPrice(tpl)
+			}
+		}
+	}
+
+	for tpl, missing := range required {
+		if missing > 0 {
+			return 0, fmt.Errorf("%d more of %s are needed", missing, tpl)
+		}
+	}
+	return paid, nil
+}
+
+// buyFromTrader pays for an assort item and adds it to the stash, as one
+// stack per stack size for stackable items and one item each otherwise
+func buyFromTrader(ctx *itemEventContext, trader *TraderStruct, action *TradingConfirmAction) error {
+	assortItems := getAssortItems(trader.baseAssort, action.ItemID)
+	if assortItems == nil {
+		return fmt.Errorf("trader %s does not sell %s", trader.base.ID, action.ItemID)
+	}
+	root := assortItems[0]
+	if action.Count < 1 {
+		return fmt.Errorf("invalid count %d", action.Count)
+	}
+
+	if err := checkAssortUnlocked(ctx.character, trader, action.ItemID); err != nil {
+		return err
+	}
+
+	schemes := trader.baseAssort.BarterScheme[action.ItemID]
+	if action.SchemeID < 0 || action.SchemeID >= len(schemes) {
+		return fmt.Errorf("unknown scheme %d of %s", action.SchemeID, action.ItemID)
+	}
+	paid, err := payBarter(ctx, schemes[action.SchemeID], action.Count, action.SchemeItems)
+	if err != nil {
+		return err
+	}
+
+	template, ok := Database.items[root.Tpl]
+	if !ok {
+		return fmt.Errorf("unknown item template %s", root.Tpl)
+	}
+	if template.Props.StackMaxSize > 1 {
+		if err := addStacksToStash(ctx, root.Tpl, root.Upd, action.Count); err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < action.Count; i++ {
+			items := cloneItems(assortItems)
+			items[0].Upd = getBoughtUpd(items[0].Upd, 1)
+			if err := addToStash(ctx, items); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := takeBuyLimit(ctx, trader, root, action.Count); err != nil {
+		return err
+	}
+	if err := takeTraderStock(trader.base.ID, root, action.Count); err != nil {
+		return err
+	}
+	ctx.rollback = append(ctx.rollback, func() {
+		returnTraderStock(trader.base.ID, root, action.Count)
+	})
+
+	getTraderInfo(ctx.character, trader.base.ID).SalesSum += toTraderCurrency(trader, paid)
+
+	profileID := ctx.profile.account.ID
+	trade := plugins.TradeResult{TraderID: trader.base.ID, Type: TRADE_BUY, ItemID: root.Tpl, Count: action.Count}
+	ctx.committed = append(ctx.committed, func() {
+		plugins.Traded(profileID, trade)
+	})
+	return nil
+}
+
+// traderBuys reports whether a trader buys an item template
+func traderBuys(trader *TraderStruct, tpl string) bool {
+	buys, prohibited := trader.base.ItemsBuy, trader.base.ItemsBuyProhibited
+	if buys == nil {
+		return false
+	}
+	if prohibited != nil && (inventory.IsOfAny(Database.items, tpl, prohibited.Category) || inventory.IsOfAny(Database.items, tpl, prohibited.IDList)) {
+		return false
+	}
+	return inventory.IsOfAny(Database.items, tpl, buys.Category) || inventory.IsOfAny(Database.items, tpl, buys.IDList)
+}
+
+// sellToTrader removes the sold items, with everything attached to or inside
+// them, and pays their handbook value less the buy coefficient of the
+// player's loyalty level into the stash, in the trader's currency
+func sellToTrader(ctx *itemEventContext, trader *TraderStruct, action *TradingConfirmAction) error {
+	if len(action.Items) == 0 {
+		return fmt.Errorf("nothing to sell")
+	}
+	currency, ok := currencyTpls[trader.base.Currency]
+	if !ok {
+		return fmt.Errorf("trader %s has unknown currency %s", trader.base.ID, trader.base.Currency)
+	}
+
+	coefficient := 0.0
+	level := getLoyaltyLevel(ctx.character, trader)
+	if level <= len(trader.base.LoyaltyLevels) {
+		coefficient = trader.base.LoyaltyLevels[level-1].BuyPriceCoef
+	}
+
+	roubles := 0.0
+	var trades []plugins.TradeResult
+	for _, sold := range action.Items {
+		item, ok := ctx.inventory.Item(sold.ID)
+		if !ok {
+			return fmt.Errorf("unknown item %s", sold.ID)
+		}
+		count := sold.Count
+		if count < 1 {
+			count = getStackCount(item)
+		}
+
+		// a whole stack is sold with everything attached to or inside it
+		items := []*structs.InventoryItem{item}
+		if count == getStackCount(item) {
+			items = ctx.inventory.Subtree(item.ID)
+		}
+		for _, soldItem := range items {
+			if !traderBuys(trader, soldItem.Tpl) {
+				return fmt.Errorf("trader %s does not buy %s", trader.base.ID, soldItem.Tpl)
+			}
+		}
+
+		value := getHandbookPrice(item.Tpl) * float64(count)
+		for _, child := range items[1:] {
+			value += getHandbookPrice(child.Tpl) * float64(getStackCount(child))
+		}
+
+		trades = append(trades, plugins.TradeResult{TraderID: trader.base.ID, Type: TRADE_SELL, ItemID: item.Tpl, Count: count})
+		if err := takeItems(ctx, sold.ID, count); err != nil {
+			return err
+		}
+		roubles += value * (100 - coefficient) / 100
+	}
+
+	amount := int(math.Floor(toTraderCurrency(trader, roubles)))
+	if amount > 0 {
+		if err := addStacksToStash(ctx, currency, nil, amount); err != nil {
+			return err
+		}
+	}
+	getTraderInfo(ctx.character, trader.base.ID).SalesSum += float64(amount)
+
+	profileID := ctx.profile.account.ID
+	ctx.committed = append(ctx.committed, func() {
+		for _, trade := range trades {
+			plugins.Traded(profileID, trade)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"MT-GO/structs"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// validationReport collects broken references found in the database
+type validationReport struct {
+	issues map[string][]string
+}
+
+func (r *validationReport) add(section string, format string, args ...interface{}) {
+	r.issues[section] = append(r.issues[section], fmt.Sprintf(format, args...))
+}
+
+func (r *validationReport) count() int {
+	total := 0
+	for _, issues := range r.issues {
+		total += len(issues)
+	}
+	return total
+}
+
+func (r *validationReport) String() string {
+	sections := make([]string, 0, len(r.issues))
+	for section := range r.issues {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	var builder strings.Builder
+	for _, section := range sections {
+		issues := r.issues[section]
+		sort.Strings(issues)
+		fmt.Fprintf(&builder, "%s (%d)\n", section, len(issues))
+		for _, issue := range issues {
+			fmt.Fprintf(&builder, "  %s\n", issue)
+		}
+	}
+	return builder.String()
+}
+
+// validateCommand loads the whole database and reports every broken
+// reference, failing if there is at least one.
+func validateCommand(args []string) error {
+	overlay, err := loadModOverlay()
+	if err != nil {
+		return fmt.Errorf("error loading mods: %w", err)
+	}
+	// profiles are left out, as loading them migrates and rewrites them and
+	// waits for the lock a running server holds on the bolt store
+	initializeDatabaseMaps(&Database)
+	if err := setDatabase(&Database, getGameDataLoaders(), overlay.read); err != nil {
+		return fmt.Errorf("error setting database: %w", err)
+	}
+
+	report := validateDatabase(&Database)
+	if report.count() == 0 {
+		fmt.Println("Database is valid")
+		return nil
+	}
+
+	fmt.Print(report.String())
+	return fmt.Errorf("database has %d broken references", report.count())
+}
+
+func validateDatabase(db *DatabaseStruct) *validationReport {
+	report := &validationReport{issues: make(map[string][]string)}
+
+	validatePresets(db, report)
+	validateTraders(db, report)
+	validateQuests(db, report)
+	validateHideout(db, report)
+	validateLocations(db, report)
+	validateLocales(db, report)
+
+	return report
+}
+
+func validatePresets(db *DatabaseStruct, report *validationReport) {
+	for tpl, presets := range db.core.presets {
+		if _, ok := db.items[tpl]; ok {
+			continue
+		}
+		for id := range presets {
+			report.add("presets", "preset %s: _tpl %s is not an item", id, tpl)
+		}
+	}
+}
+
+func validateTraders(db *DatabaseStruct, report *validationReport) {
+	for traderID, trader := range db.traders {
+		assort := trader.baseAssort
+		assortIDs := make(map[string]bool, len(assort.Items))
+
+		for _, item := range assort.Items {
+			assortIDs[item.ID] = true
+			if _, ok := db.items[item.Tpl]; !ok {
+				report.add("trader assorts", "trader %s: assort item %s: _tpl %s is not an item", traderID, item.ID, item.Tpl)
+			}
+		}
+
+		for assortID, schemes := range assort.BarterScheme {
+			if !assortIDs[assortID] {
+				report.add("trader assorts", "trader %s: barter_scheme %s is not an assort item", traderID, assortID)
+			}
+			for _, scheme := range schemes {
+				for _, barter := range scheme {
+					if _, ok := db.items[barter.Tpl]; !ok {
+						report.add("trader assorts", "trader %s: barter_scheme %s: _tpl %s is not an item", traderID, assortID, barter.Tpl)
+					}
+				}
+			}
+		}
+
+		for assortID := range assort.LoyalLevelItems {
+			if !assortIDs[assortID] {
+				report.add("trader assorts", "trader %s: loyal_level_items %s is not an assort item", traderID, assortID)
+			}
+		}
+
+		for status, unlocks := range trader.questAssort {
+			for assortID, questID := range unlocks {
+				if !assortIDs[assortID] {
+					report.add("trader quest assorts", "trader %s: %s %s is not an assort item", traderID, status, assortID)
+				}
+				if _, ok := db.quests[questID]; !ok {
+					report.add("trader quest assorts", "trader %s: %s %s unlocks with quest %s, which does not exist", traderID, status, assortID, questID)
+				}
+			}
+		}
+	}
+}
+
+func validateQuests(db *DatabaseStruct, report *validationReport) {
+	for questID, quest := range db.quests {
+		if _, ok := db.traders[quest.TraderID]; !ok {
+			report.add("quests", "quest %s: traderId %s is not a trader", questID, quest.TraderID)
+		}
+
+		rewards := map[string][]*structs.QuestReward{
+			"Started": quest.Rewards.Started,
+			"Success": quest.Rewards.Success,
+			"Fail":    quest.Rewards.Fail,
+		}
+		for status, list := range rewards {
+			for _, reward := range list {
+				if reward.TraderID != "" {
+					if _, ok := db.traders[reward.TraderID]; !ok {
+						report.add("quests", "quest %s: %s reward %s: traderId %s is not a trader", questID, status, reward.ID, reward.TraderID)
+					}
+				}
+				for _, item := range reward.Items {
+					if _, ok := db.items[item.Tpl]; !ok {
+						report.add("quests", "quest %s: %s reward %s: _tpl %s is not an item", questID, status, reward.ID, item.Tpl)
+					}
+				}
+			}
+		}
+	}
+}
+
+func validateHideout(db *DatabaseStruct, report *validationReport) {
+	for _, production := range db.hideout.productions {
+		if _, ok := db.items[production.EndProduct]; !ok {
+			report.add("hideout productions", "production %s: endProduct %s is not an item", production.ID, production.EndProduct)
+		}
+		for _, requirement := range production.Requirements {
+			if requirement.TemplateID == "" {
+				continue
+			}
+			if _, ok := db.items[requirement.TemplateID]; !ok {
+				report.add("hideout productions", "production %s: requirement templateId %s is not an item", production.ID, requirement.TemplateID)
+			}
+		}
+	}
+
+	for _, area := range db.hideout.areas {
+		for level, stage := range area.Stages {
+			for _, requirement := range stage.Requirements {
+				if requirement.TemplateID != "" {
+					if _, ok := db.items[requirement.TemplateID]; !ok {
+						report.add("hideout areas", "area %d stage %s: requirement templateId %s is not an item", area.Type, level, requirement.TemplateID)
+					}
+				}
+				if requirement.TraderID != "" {
+					if _, ok := db.traders[requirement.TraderID]; !ok {
+						report.add("hideout areas", "area %d stage %s: requirement traderId %s is not a trader", area.Type, level, requirement.TraderID)
+					}
+				}
+			}
+		}
+	}
+}
+
+func validateLocations(db *DatabaseStruct, report *validationReport) {
+	for name, location := range db.locations.locations {
+		for file, spawns := range location.lootSpawns {
+			entries, ok := spawns.([]map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, entry := range entries {
+				worldID, _ := entry["worldId"].(string)
+				if tpl, ok := entry["containerTpl"].(string); ok {
+					if _, ok := db.locations.lootGen.containers[tpl]; !ok {
+						report.add("loot spawns", "%s/%s %s: containerTpl %s has no container spawn data", name, file, worldID, tpl)
+					}
+				}
+				if tpl, ok := entry["questItmTpl"].(string); ok {
+					if _, ok := db.items[tpl]; !ok {
+						report.add("loot spawns", "%s/%s %s: questItmTpl %s is not an item", name, file, worldID, tpl)
+					}
+				}
+			}
+		}
+	}
+
+	for containerTpl, container := range db.locations.lootGen.containers {
+		data, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		spawnList, _ := data["SpawnList"].([]interface{})
+		for _, entry := range spawnList {
+			if tpl, ok := entry.(string); ok {
+				if _, ok := db.items[tpl]; !ok {
+					report.add("loot containers", "container %s: SpawnList %s is not an item", containerTpl, tpl)
+				}
+			}
+		}
+	}
+}
+
+// validateLocales reports keys every other language is missing compared to english
+func validateLocales(db *DatabaseStruct, report *validationReport) {
+	english, ok := db.locales.locales["en"].(LanguageStruct)
+	if !ok {
+		report.add("locales", "en locale is missing")
+		return
+	}
+
+	for lang, value := range db.locales.locales {
+		if lang == "en" {
+			continue
+		}
+		language, ok := value.(LanguageStruct)
+		if !ok {
+			continue
+		}
+
+		for key := range english.locale {
+			if _, ok := language.locale[key]; !ok {
+				report.add("locales", "%s: locale.json is missing %q", lang, key)
+			}
+		}
+
+		englishMenu, _ := english.menu["menu"].(map[string]interface{})
+		languageMenu, _ := language.menu["menu"].(map[string]interface{})
+		for key := range englishMenu {
+			if _, ok := languageMenu[key]; !ok {
+				report.add("locales", "%s: menu.json is missing %q", lang, key)
+			}
+		}
+	}
+}